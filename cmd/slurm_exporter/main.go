@@ -1,18 +1,27 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/sckyzo/slurm_prometheus_exporter/internal/collector"
 	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/logsink"
 	"github.com/sckyzo/slurm_prometheus_exporter/internal/metrics"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/otlp"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/pushgateway"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/remotewrite"
 	"github.com/sckyzo/slurm_prometheus_exporter/internal/server"
 )
 
@@ -35,18 +44,41 @@ var (
 			Default("info").
 			String()
 
-	logFormat = kingpin.Flag("log.format", "Log format (text, json)").
-			Default("text").
-			String()
+	logFormat = kingpin.Flag("log.format", "Log format (text, json); overrides logging.format").
+			Enum("text", "json")
 
 	showVersion = kingpin.Flag("version", "Show version information").
 			Short('v').
 			Bool()
+
+	pushGateway = kingpin.Flag("push.gateway", "Pushgateway URL; collect all endpoints once, push them and exit").
+			String()
+
+	pushJob = kingpin.Flag("push.job", "Job name used when pushing to the Pushgateway").
+		Default("slurm_exporter").
+		String()
+
+	pushInstance = kingpin.Flag("push.instance", "Instance grouping label used when pushing to the Pushgateway (default: hostname)").
+			String()
+
+	onceTimeout = kingpin.Flag("once.timeout", "Timeout of a one-shot collection").
+			Default("2m").
+			Duration()
+
+	// Commands
+	serveCommand = kingpin.Command("serve", "Run the exporter HTTP server").Default()
+	onceCommand  = kingpin.Command("once", "Collect all endpoints once, write them to stdout or push them with --push.gateway, and exit")
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run runs the command and returns the process exit code. Deferred calls,
+// such as closing the log output, run before main exits.
+func run() int {
 	// Parse command-line arguments
-	kingpin.Parse()
+	command := kingpin.Parse()
 
 	// Show version information if requested
 	if *showVersion {
@@ -54,14 +86,14 @@ func main() {
 		fmt.Printf("Version:    %s\n", Version)
 		fmt.Printf("Git Commit: %s\n", GitCommit)
 		fmt.Printf("Build Time: %s\n", BuildTime)
-		os.Exit(0)
+		return 0
 	}
 
 	// Load configuration
 	cfg, err := config.Load(*configFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
 	// Override config with CLI flags if provided
@@ -79,14 +111,23 @@ func main() {
 	if *logLevel != "info" {
 		cfg.Logging.Level = *logLevel
 	}
-	if *logFormat != "text" {
-		if *logFormat == "json" {
-			cfg.Logging.Output = "json"
-		}
+	if *logFormat != "" {
+		cfg.Logging.Format = *logFormat
 	}
 
-	// Setup logging
-	logger := setupLogger(cfg.Logging)
+	// Setup logging; a one-shot collection writes metrics to stdout, so its
+	// logs go to stderr instead
+	once := command == onceCommand.FullCommand() || *pushGateway != ""
+	if once && cfg.Logging.Output == "stdout" {
+		cfg.Logging.Output = "stderr"
+	}
+	logOutput, err := logsink.Open(cfg.Logging.LogSinkConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open log output: %v\n", err)
+		return 1
+	}
+	defer logOutput.Close()
+	logger := setupLogger(cfg.Logging, logOutput)
 	logger.Info("starting slurm exporter",
 		"version", Version,
 		"git_commit", GitCommit,
@@ -100,28 +141,78 @@ func main() {
 	coll, err := collector.NewCollector(cfg, metricsRegistry, logger)
 	if err != nil {
 		logger.Error("failed to create collector", "error", err)
-		os.Exit(1)
+		return 1
+	}
+	defer coll.Close()
+
+	// Collect once and exit when run from cron
+	if once {
+		return runOnce(cfg, coll, logger)
 	}
 
 	// Check Slurm API health
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if err := coll.Health(ctx); err != nil {
-		logger.Warn("slurm API health check failed, but continuing anyway",
-			"error", err,
-			"url", cfg.Slurm.URL)
-	} else {
-		logger.Info("slurm API health check passed", "url", cfg.Slurm.URL)
+	for _, upstream := range coll.CheckUpstreams(ctx) {
+		if upstream.Err != nil {
+			logger.Warn("slurm API health check failed, but continuing anyway",
+				"error", upstream.Err,
+				"url", upstream.URL)
+		} else {
+			logger.Info("slurm API health check passed", "url", upstream.URL)
+		}
+	}
+
+	// Push metrics to a remote write receiver if enabled
+	pushCtx, stopPush := context.WithCancel(context.Background())
+	defer stopPush()
+	var pushers sync.WaitGroup
+	if cfg.RemoteWrite.Enabled {
+		pusher, err := remotewrite.NewPusher(cfg.RemoteWrite, coll, metricsRegistry, logger, Version)
+		if err != nil {
+			logger.Error("failed to create remote write pusher", "error", err)
+			return 1
+		}
+		pushers.Add(1)
+		go func() {
+			defer pushers.Done()
+			pusher.Run(pushCtx)
+		}()
+	}
+
+	// Export metrics to an OTLP collector if enabled
+	if cfg.OTLP.Enabled {
+		exporter, err := otlp.NewExporter(cfg.OTLP, cfg.Labels, coll, metricsRegistry, logger, Version)
+		if err != nil {
+			logger.Error("failed to create OTLP exporter", "error", err)
+			return 1
+		}
+		pushers.Add(1)
+		go func() {
+			defer pushers.Done()
+			exporter.Run(pushCtx)
+		}()
 	}
 
 	// Create HTTP server
 	srv := server.NewServer(cfg, coll, metricsRegistry, logger, Version)
 
+	// Write the access log to its own sink if enabled
+	if cfg.Server.AccessLog.Enabled {
+		accessLog, err := logsink.Open(cfg.Server.AccessLog.LogSinkConfig)
+		if err != nil {
+			logger.Error("failed to open access log", "error", err)
+			return 1
+		}
+		defer accessLog.Close()
+		srv.SetAccessLog(accessLog)
+	}
+
 	// Start server in a goroutine
+	serverErr := make(chan error, 1)
 	go func() {
-		if err := srv.Start(); err != nil {
-			logger.Error("server failed", "error", err)
-			os.Exit(1)
+		if err := srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
 	}()
 
@@ -132,24 +223,102 @@ func main() {
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	select {
+	case <-quit:
+	case err := <-serverErr:
+		logger.Error("server failed", "error", err)
+		stopPush()
+		pushers.Wait()
+		return 1
+	}
 
 	logger.Info("shutting down exporter...")
 
+	stopPush()
+	pushers.Wait()
+
 	// Give the server 10 seconds to finish ongoing requests
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 
 	if err := srv.Stop(shutdownCtx); err != nil {
 		logger.Error("server shutdown failed", "error", err)
-		os.Exit(1)
+		return 1
 	}
 
 	logger.Info("exporter stopped successfully")
+	return 0
+}
+
+// runOnce collects all enabled endpoints and pushes the result to the
+// Pushgateway, or writes it to stdout when no gateway is configured. It
+// returns the process exit code: 1 if any endpoint could not be collected.
+func runOnce(cfg *config.Config, coll *collector.Collector, logger *slog.Logger) int {
+	ctx, cancel := context.WithTimeout(context.Background(), *onceTimeout)
+	defer cancel()
+
+	results, err := coll.CollectAll(ctx)
+	if err != nil {
+		logger.Error("failed to collect metrics", "error", err)
+		return 1
+	}
+
+	// Keep the configured endpoint order so the output is stable
+	endpoints := cfg.GetEnabledEndpoints()
+	var payload bytes.Buffer
+	for _, endpoint := range endpoints {
+		payload.WriteString(results[endpoint.Name])
+	}
+
+	exitCode := 0
+	if len(results) < len(endpoints) {
+		logger.Error("some endpoints could not be collected",
+			"collected", len(results),
+			"enabled", len(endpoints))
+		exitCode = 1
+	}
+
+	if *pushGateway == "" {
+		if _, err := os.Stdout.Write(payload.Bytes()); err != nil {
+			logger.Error("failed to write metrics", "error", err)
+			return 1
+		}
+		return exitCode
+	}
+
+	// Group by cluster and instance; the cluster comes from the custom labels
+	// already added to every series
+	cluster, ok := cfg.Labels["cluster"]
+	if !ok {
+		logger.Error("labels.cluster must be configured to push to the Pushgateway")
+		return 1
+	}
+	instance := *pushInstance
+	if instance == "" {
+		if instance, err = os.Hostname(); err != nil {
+			logger.Error("failed to determine the instance label", "error", err)
+			return 1
+		}
+	}
+
+	groupURL := pushgateway.URL(*pushGateway, *pushJob, []pushgateway.GroupingLabel{
+		{Name: "cluster", Value: cluster},
+		{Name: "instance", Value: instance},
+	})
+	if err := pushgateway.Push(ctx, http.DefaultClient, groupURL, payload.Bytes()); err != nil {
+		logger.Error("failed to push metrics", "url", groupURL, "error", err)
+		return 1
+	}
+
+	logger.Info("pushed metrics to the pushgateway",
+		"url", groupURL,
+		"endpoints", len(results),
+		"bytes", payload.Len())
+	return exitCode
 }
 
 // setupLogger configures the structured logger based on the configuration
-func setupLogger(cfg config.LoggingConfig) *slog.Logger {
+func setupLogger(cfg config.LoggingConfig, output io.Writer) *slog.Logger {
 	var level slog.Level
 	switch cfg.Level {
 	case "debug":
@@ -168,12 +337,5 @@ func setupLogger(cfg config.LoggingConfig) *slog.Logger {
 		Level: level,
 	}
 
-	var handler slog.Handler
-	if cfg.Output == "json" {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
-	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
-	}
-
-	return slog.New(handler)
+	return slog.New(logsink.NewHandler(output, cfg.Format, opts))
 }
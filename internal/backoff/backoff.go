@@ -0,0 +1,22 @@
+// Package backoff computes the delays between retries of upstream and
+// downstream requests
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Jittered returns the delay before the given retry, starting at 1, using
+// full jitter: a random duration up to the exponential delay doubling from
+// initial and capped at maximum
+func Jittered(initial, maximum time.Duration, retry int) time.Duration {
+	delay := initial << (retry - 1)
+	if delay <= 0 || delay > maximum {
+		delay = maximum
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(delay) + 1))
+}
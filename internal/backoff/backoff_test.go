@@ -0,0 +1,32 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJittered(t *testing.T) {
+	tests := []struct {
+		retry int
+		limit time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		// The shift overflows: the maximum applies
+		{80, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if delay := Jittered(100*time.Millisecond, time.Second, tt.retry); delay < 0 || delay > tt.limit {
+				t.Fatalf("Retry %d: delay %v outside [0, %v]", tt.retry, delay, tt.limit)
+			}
+		}
+	}
+
+	if delay := Jittered(0, 0, 3); delay != 0 {
+		t.Errorf("Expected no delay without a backoff, got %v", delay)
+	}
+}
@@ -0,0 +1,262 @@
+package collector
+
+import (
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// overflowValue is the label value series beyond a cardinality limit are aggregated into
+const overflowValue = "__other__"
+
+// structuralLabels are never rewritten since they describe the shape of a
+// histogram or summary rather than a dimension
+var structuralLabels = map[string]bool{"le": true, "quantile": true}
+
+// limitCardinality enforces the per-label and per-endpoint limits of the
+// endpoint. Series beyond a limit are not dropped but summed into a series
+// carrying the "__other__" label value; summary quantiles cannot be summed,
+// so only the _sum and _count of overflow summaries are kept. It returns the
+// number of upstream series that were folded into an overflow series.
+func limitCardinality(endpoint config.EndpointConfig, families []*openmetrics.Family) int {
+	folded := 0
+
+	// Per-label limits: the first values in upstream order are kept
+	if len(endpoint.MaxValues) > 0 {
+		admitted := make(map[string]map[string]bool, len(endpoint.MaxValues))
+		for label := range endpoint.MaxValues {
+			admitted[label] = make(map[string]bool)
+		}
+
+		for _, family := range families {
+			for i := range family.Samples {
+				sample := &family.Samples[i]
+				overflow := false
+				for j := range sample.Labels {
+					l := &sample.Labels[j]
+					limit, ok := endpoint.MaxValues[l.Name]
+					if !ok {
+						continue
+					}
+					values := admitted[l.Name]
+					if !values[l.Value] {
+						if len(values) >= limit {
+							l.Value = overflowValue
+							overflow = true
+							continue
+						}
+						values[l.Value] = true
+					}
+				}
+				if overflow {
+					folded++
+				}
+			}
+		}
+	}
+
+	if folded > 0 {
+		for _, family := range families {
+			mergeDuplicateSamples(family)
+		}
+	}
+
+	if endpoint.MaxSeries > 0 {
+		folded += limitSeries(endpoint.MaxSeries, families)
+	}
+
+	return folded
+}
+
+// seriesGroup is a set of samples of a family sharing their dimensions, such
+// as the buckets, sum and count of one histogram series
+type seriesGroup struct {
+	samples []int
+}
+
+// familyBudget tracks how many groups of a family keep their dimensions
+type familyBudget struct {
+	family *openmetrics.Family
+	groups []seriesGroup
+
+	// prefix[k] is the number of samples of the first k groups and
+	// overflow[k] the number of overflow series the groups from k on
+	// collapse into
+	prefix   []int
+	overflow []int
+
+	kept int
+	done bool
+}
+
+// cost returns the number of series of the family when k groups are kept
+func (b *familyBudget) cost(k int) int {
+	return b.prefix[k] + b.overflow[k]
+}
+
+// limitSeries caps the number of series of the endpoint at maxSeries,
+// overflow series included. Groups beyond the budget lose their dimensions
+// and are summed into one overflow series per family and shape. The budget
+// is shared out round-robin so that every family keeps a similar number of
+// groups. Every family keeps at least its overflow series, so the cap is
+// exceeded when the fully collapsed families alone do not fit. It returns the
+// number of upstream series that were folded.
+func limitSeries(maxSeries int, families []*openmetrics.Family) int {
+	if countSeries(families) <= maxSeries {
+		return 0
+	}
+
+	budgets := make([]*familyBudget, 0, len(families))
+	for _, family := range families {
+		budgets = append(budgets, newFamilyBudget(family))
+	}
+
+	// Start with every family fully collapsed
+	total := 0
+	for _, b := range budgets {
+		total += b.cost(0)
+	}
+
+	// Then admit one group per family and round while the budget allows
+	for admitted := true; admitted; {
+		admitted = false
+		for _, b := range budgets {
+			if b.done {
+				continue
+			}
+			if b.kept == len(b.groups) {
+				b.done = true
+				continue
+			}
+			delta := b.cost(b.kept+1) - b.cost(b.kept)
+			if total+delta > maxSeries {
+				b.done = true
+				continue
+			}
+			total += delta
+			b.kept++
+			admitted = true
+		}
+	}
+
+	folded := 0
+	for _, b := range budgets {
+		for _, group := range b.groups[b.kept:] {
+			for _, i := range group.samples {
+				if collapse(&b.family.Samples[i]) {
+					folded++
+				}
+			}
+		}
+		mergeDuplicateSamples(b.family)
+	}
+	return folded
+}
+
+// newFamilyBudget groups the samples of a family by dimensions, in upstream
+// order, and computes the cost of keeping each number of groups
+func newFamilyBudget(family *openmetrics.Family) *familyBudget {
+	b := &familyBudget{family: family}
+
+	index := make(map[string]int)
+	for i := range family.Samples {
+		key := dimensionsKey(&family.Samples[i])
+		g, ok := index[key]
+		if !ok {
+			g = len(b.groups)
+			index[key] = g
+			b.groups = append(b.groups, seriesGroup{})
+		}
+		b.groups[g].samples = append(b.groups[g].samples, i)
+	}
+
+	b.prefix = make([]int, len(b.groups)+1)
+	for k, group := range b.groups {
+		b.prefix[k+1] = b.prefix[k] + len(group.samples)
+	}
+
+	b.overflow = make([]int, len(b.groups)+1)
+	shapes := make(map[string]bool)
+	for k := len(b.groups) - 1; k >= 0; k-- {
+		for _, i := range b.groups[k].samples {
+			if !isQuantile(family, &family.Samples[i]) {
+				shapes[collapsedKey(family.Samples[i])] = true
+			}
+		}
+		b.overflow[k] = len(shapes)
+	}
+	return b
+}
+
+// dimensionsKey identifies the dimensions of a sample, ignoring its name and
+// structural labels
+func dimensionsKey(sample *openmetrics.Sample) string {
+	dimensions := openmetrics.Sample{}
+	for _, l := range sample.Labels {
+		if !structuralLabels[l.Name] {
+			dimensions.Labels = append(dimensions.Labels, l)
+		}
+	}
+	return dimensions.Key()
+}
+
+// collapsedKey returns the key of the overflow series the sample folds into
+func collapsedKey(sample openmetrics.Sample) string {
+	sample = sample.Clone()
+	collapse(&sample)
+	return sample.Key()
+}
+
+// collapse replaces the values of the dimensions of a sample with the
+// overflow value and reports whether it had any
+func collapse(sample *openmetrics.Sample) bool {
+	changed := false
+	for j := range sample.Labels {
+		if !structuralLabels[sample.Labels[j].Name] {
+			sample.Labels[j].Value = overflowValue
+			changed = true
+		}
+	}
+	return changed
+}
+
+// isQuantile reports whether the sample is a quantile of a summary, which
+// cannot be summed with another one
+func isQuantile(family *openmetrics.Family, sample *openmetrics.Sample) bool {
+	if family.Type != openmetrics.TypeSummary {
+		return false
+	}
+	_, ok := sample.Label("quantile")
+	return ok
+}
+
+// isOverflow reports whether a dimension of the sample holds the overflow
+// value
+func isOverflow(sample *openmetrics.Sample) bool {
+	for _, l := range sample.Labels {
+		if !structuralLabels[l.Name] && l.Value == overflowValue {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeDuplicateSamples sums samples sharing the same name and labels,
+// keeping the position of the first occurrence. Overflow summary quantiles
+// are removed since the sum of quantiles means nothing.
+func mergeDuplicateSamples(family *openmetrics.Family) {
+	index := make(map[string]int, len(family.Samples))
+	merged := family.Samples[:0]
+	for _, sample := range family.Samples {
+		if isQuantile(family, &sample) && isOverflow(&sample) {
+			continue
+		}
+		key := sample.Key()
+		if i, ok := index[key]; ok {
+			merged[i].Value += sample.Value
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, sample)
+	}
+	family.Samples = merged
+}
@@ -0,0 +1,191 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+const userJobs = `# HELP slurm_user_jobs_running Number of jobs in Running state
+# TYPE slurm_user_jobs_running gauge
+slurm_user_jobs_running{username="alice"} 1
+slurm_user_jobs_running{username="bob"} 2
+slurm_user_jobs_running{username="carol"} 3
+slurm_user_jobs_running{username="dave"} 4
+# HELP slurm_user_jobs_pending Number of jobs in Pending state
+# TYPE slurm_user_jobs_pending gauge
+slurm_user_jobs_pending{username="dave"} 5
+slurm_user_jobs_pending{username="alice"} 6
+`
+
+func TestLimitCardinalityMaxValues(t *testing.T) {
+	families, err := openmetrics.Parse(strings.NewReader(userJobs))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	endpoint := config.EndpointConfig{Name: "jobs-users-accts", MaxValues: map[string]int{"username": 2}}
+	folded := limitCardinality(endpoint, families)
+	if folded != 3 {
+		t.Errorf("Expected 3 folded series, got %d", folded)
+	}
+
+	var out strings.Builder
+	if err := openmetrics.Write(&out, families); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	for _, want := range []string{
+		`slurm_user_jobs_running{username="alice"} 1`,
+		`slurm_user_jobs_running{username="bob"} 2`,
+		`slurm_user_jobs_running{username="__other__"} 7`,
+		`slurm_user_jobs_pending{username="__other__"} 5`,
+		`slurm_user_jobs_pending{username="alice"} 6`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestLimitCardinalityMaxSeries(t *testing.T) {
+	families, err := openmetrics.Parse(strings.NewReader(userJobs))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	// Both families start collapsed (2 series), then alice is admitted for
+	// the first family and the budget is spent
+	endpoint := config.EndpointConfig{Name: "jobs-users-accts", MaxSeries: 3}
+	if folded := limitCardinality(endpoint, families); folded != 5 {
+		t.Errorf("Expected 5 folded series, got %d", folded)
+	}
+
+	if count := countSeries(families); count != 3 {
+		t.Errorf("Expected 3 series after aggregation, got %d", count)
+	}
+	other := families[0].Samples[1]
+	if user, _ := other.Label("username"); user != overflowValue || other.Value != 9 {
+		t.Errorf("Expected __other__ series with value 9, got %s=%v", user, other.Value)
+	}
+	if pending := families[1].Samples; len(pending) != 1 || pending[0].Value != 11 {
+		t.Errorf("Expected a single pending __other__ series with value 11, got %v", pending)
+	}
+}
+
+const mixedFamilies = `# TYPE slurm_node_cpus gauge
+slurm_node_cpus{node="c1"} 1
+slurm_node_cpus{node="c2"} 2
+slurm_node_cpus{node="c3"} 3
+slurm_node_cpus{node="c4"} 4
+# TYPE slurm_node_mem gauge
+slurm_node_mem{node="c1"} 1
+slurm_node_mem{node="c2"} 2
+slurm_node_mem{node="c3"} 3
+slurm_node_mem{node="c4"} 4
+# TYPE slurm_rpc_latency histogram
+slurm_rpc_latency_bucket{rpc="a",le="1"} 1
+slurm_rpc_latency_bucket{rpc="a",le="+Inf"} 2
+slurm_rpc_latency_sum{rpc="a"} 3
+slurm_rpc_latency_count{rpc="a"} 2
+slurm_rpc_latency_bucket{rpc="b",le="1"} 1
+slurm_rpc_latency_bucket{rpc="b",le="+Inf"} 1
+slurm_rpc_latency_sum{rpc="b"} 1
+slurm_rpc_latency_count{rpc="b"} 1
+# TYPE slurm_jobs gauge
+slurm_jobs 7
+`
+
+func TestLimitCardinalityMaxSeriesCap(t *testing.T) {
+	// Fully collapsed, the families need 7 series: one per gauge and the
+	// four series of the histogram
+	for maxSeries := 1; maxSeries <= 19; maxSeries++ {
+		families, err := openmetrics.Parse(strings.NewReader(mixedFamilies))
+		if err != nil {
+			t.Fatalf("Failed to parse: %v", err)
+		}
+
+		folded := limitCardinality(config.EndpointConfig{Name: "nodes", MaxSeries: maxSeries}, families)
+		count := countSeries(families)
+		if count > max(maxSeries, 7) {
+			t.Errorf("max_series %d: got %d series", maxSeries, count)
+		}
+		for _, family := range families {
+			if len(family.Samples) == 0 {
+				t.Errorf("max_series %d: expected %s to be kept", maxSeries, family.Name)
+			}
+		}
+		if maxSeries >= 19 && folded != 0 {
+			t.Errorf("max_series %d: expected nothing folded, got %d", maxSeries, folded)
+		}
+	}
+
+	// With room for 12 series, both gauges keep about as many nodes and the
+	// unlabelled family is left alone
+	families, _ := openmetrics.Parse(strings.NewReader(mixedFamilies))
+	limitCardinality(config.EndpointConfig{Name: "nodes", MaxSeries: 12}, families)
+	if cpus, mem := len(families[0].Samples), len(families[1].Samples); cpus-mem > 1 || mem-cpus > 1 {
+		t.Errorf("Expected the budget to be shared evenly, got %d and %d series", cpus, mem)
+	}
+	if jobs := families[3].Samples; len(jobs) != 1 || jobs[0].Value != 7 {
+		t.Errorf("Expected slurm_jobs to be unchanged, got %v", jobs)
+	}
+}
+
+const userLatency = `# TYPE slurm_user_rpc_latency summary
+slurm_user_rpc_latency{username="alice",quantile="0.99"} 3
+slurm_user_rpc_latency_sum{username="alice"} 10
+slurm_user_rpc_latency_count{username="alice"} 5
+slurm_user_rpc_latency{username="bob",quantile="0.99"} 4
+slurm_user_rpc_latency_sum{username="bob"} 20
+slurm_user_rpc_latency_count{username="bob"} 6
+slurm_user_rpc_latency{username="carol",quantile="0.99"} 5
+slurm_user_rpc_latency_sum{username="carol"} 30
+slurm_user_rpc_latency_count{username="carol"} 7
+`
+
+func TestLimitCardinalitySummary(t *testing.T) {
+	for _, endpoint := range []config.EndpointConfig{
+		{Name: "users", MaxValues: map[string]int{"username": 1}},
+		{Name: "users", MaxSeries: 5},
+	} {
+		families, err := openmetrics.Parse(strings.NewReader(userLatency))
+		if err != nil {
+			t.Fatalf("Failed to parse: %v", err)
+		}
+		limitCardinality(endpoint, families)
+
+		var out strings.Builder
+		if err := openmetrics.Write(&out, families); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+
+		// alice is kept whole, the others lose their quantile but their
+		// sums and counts are added up
+		for _, want := range []string{
+			`slurm_user_rpc_latency{username="alice",quantile="0.99"} 3`,
+			`slurm_user_rpc_latency_sum{username="__other__"} 50`,
+			`slurm_user_rpc_latency_count{username="__other__"} 13`,
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%+v: expected output to contain %q, got:\n%s", endpoint, want, out.String())
+			}
+		}
+		if strings.Contains(out.String(), `username="__other__",quantile`) {
+			t.Errorf("%+v: expected no overflow quantile, got:\n%s", endpoint, out.String())
+		}
+	}
+}
+
+func TestLimitCardinalityUnlimited(t *testing.T) {
+	families, err := openmetrics.Parse(strings.NewReader(userJobs))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	if folded := limitCardinality(config.EndpointConfig{Name: "jobs-users-accts"}, families); folded != 0 {
+		t.Errorf("Expected no folded series, got %d", folded)
+	}
+}
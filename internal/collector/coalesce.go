@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"context"
+	"sync"
+)
+
+// flight is a collection shared by concurrent scrapes of an endpoint
+type flight struct {
+	done    chan struct{}
+	text    string
+	ok      bool
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// coalescer lets concurrent scrapes share one in-flight collection per
+// endpoint. The collection runs detached from the request that started it and
+// is only cancelled once every waiting request has gone away.
+type coalescer struct {
+	joined func(key string)
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// newCoalescer creates a coalescer calling joined whenever a request joins a
+// collection started by another request
+func newCoalescer(joined func(key string)) *coalescer {
+	return &coalescer{joined: joined, flights: make(map[string]*flight)}
+}
+
+// do returns the result of the in-flight collection for key, starting one
+// with fn if none is running. When ctx is done before the collection
+// completes, do returns ctx.Err().
+func (g *coalescer) do(ctx context.Context, key string, fn func(context.Context) (string, bool)) (string, bool, error) {
+	g.mu.Lock()
+	f, shared := g.flights[key]
+	if shared {
+		f.waiters++
+		g.joined(key)
+	} else {
+		// Keep the deadline of the first request but not its cancellation
+		var flightCtx context.Context
+		var cancel context.CancelFunc
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline {
+			flightCtx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+		} else {
+			flightCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+		}
+
+		f = &flight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.flights[key] = f
+
+		go func() {
+			f.text, f.ok = fn(flightCtx)
+			g.mu.Lock()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+			g.mu.Unlock()
+			cancel()
+			close(f.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.text, f.ok, nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Nobody is waiting anymore: stop the upstream requests and let
+			// the next scrape start a fresh collection
+			f.cancel()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+		}
+		g.mu.Unlock()
+		return "", false, ctx.Err()
+	}
+}
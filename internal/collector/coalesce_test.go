@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+func TestCoalescedScrapes(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Write([]byte("# TYPE slurm_jobs gauge\nslurm_jobs 1\n"))
+	}))
+	defer server.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+
+	endpoint := config.EndpointConfig{Name: "coalesced", Path: "/metrics/jobs", Enabled: true}
+	cfg := testConfig(server.URL, endpoint)
+	cfg.Server.CoalesceScrapes = true
+	c := newTestCollector(t, cfg)
+	coalesced := c.registry.CoalescedRequests.WithLabelValues(endpoint.Name)
+	before := counterValue(t, coalesced)
+
+	// The first scrape gives up while the collection is in flight
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		c.CollectAll(leaderCtx)
+	}()
+	waitFor(t, func() bool { return requests.Load() == 1 })
+
+	var wg sync.WaitGroup
+	results := make([]map[string]string, 3)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = c.CollectAll(context.Background())
+		}()
+	}
+	waitFor(t, func() bool { return counterValue(t, coalesced)-before == 3 })
+
+	cancelLeader()
+	<-leaderDone
+	close(release)
+	wg.Wait()
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected a single upstream request, got %d", n)
+	}
+	for i, result := range results {
+		if result[endpoint.Name] != "# TYPE slurm_jobs gauge\nslurm_jobs 1\n" {
+			t.Errorf("Scrape %d got %q", i, result[endpoint.Name])
+		}
+	}
+}
+
+func TestCoalescerCancelsAbandonedCollection(t *testing.T) {
+	g := newCoalescer(func(string) {})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stopped := make(chan struct{})
+	go func() {
+		_, _, err := g.do(ctx, "jobs", func(ctx context.Context) (string, bool) {
+			<-ctx.Done()
+			close(stopped)
+			return "", false
+		})
+		if err == nil {
+			t.Errorf("Expected the cancelled request to fail")
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the collection to be cancelled once no request waits for it")
+	}
+}
+
+// waitFor polls until the condition holds or fails the test after a second
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
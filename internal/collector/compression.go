@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// acceptEncoding builds the Accept-Encoding header from the configured
+// encodings, in order of preference
+func acceptEncoding(encodings []string) string {
+	return strings.Join(encodings, ", ")
+}
+
+// decodeBody wraps a response body according to its Content-Encoding. The
+// returned close function releases the decoder.
+func decodeBody(body io.Reader, contentEncoding string) (io.Reader, func(), error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return body, func() {}, nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read gzip response: %w", err)
+		}
+		return reader, func() { reader.Close() }, nil
+	case "zstd":
+		reader, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read zstd response: %w", err)
+		}
+		return reader, reader.Close, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported content encoding %q", contentEncoding)
+}
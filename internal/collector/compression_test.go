@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+// counterValue reads the current value of a counter
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	t.Helper()
+	var metric dto.Metric
+	if err := counter.Write(&metric); err != nil {
+		t.Fatalf("Failed to read counter: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	payload := []byte("# TYPE slurm_jobs gauge\nslurm_jobs 1\n" +
+		"# TYPE slurm_node_cpus gauge\nslurm_node_cpus{node=\"c001\"} 64\n")
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(payload)
+	gz.Close()
+
+	encoder, _ := zstd.NewWriter(nil)
+	zstded := encoder.EncodeAll(payload, nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted := r.Header.Get("Accept-Encoding")
+		switch {
+		case strings.HasPrefix(accepted, "zstd"):
+			w.Header().Set("Content-Encoding", "zstd")
+			w.Write(zstded)
+		case strings.HasPrefix(accepted, "gzip"):
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped.Bytes())
+		default:
+			w.Write(payload)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		encoding    string
+		transferred int
+	}{
+		{"zstd", len(zstded)},
+		{"gzip", gzipped.Len()},
+		{"identity", len(payload)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			endpoint := config.EndpointConfig{Name: "compression-" + tt.encoding, Path: "/metrics/jobs", Enabled: true}
+			cfg := testConfig(server.URL, endpoint)
+			cfg.Slurm.Compression = []string{tt.encoding}
+			c := newTestCollector(t, cfg)
+
+			text, err := c.collectEndpoint(context.Background(), endpoint)
+			if err != nil {
+				t.Fatalf("Failed to collect: %v", err)
+			}
+			if text != string(payload) {
+				t.Errorf("Unexpected output %q", text)
+			}
+
+			transferred := counterValue(t, c.registry.UpstreamTransferredBytes.WithLabelValues(endpoint.Name))
+			decoded := counterValue(t, c.registry.UpstreamDecodedBytes.WithLabelValues(endpoint.Name))
+			if transferred != float64(tt.transferred) || decoded != float64(len(payload)) {
+				t.Errorf("Expected %d transferred and %d decoded bytes, got %v and %v",
+					tt.transferred, len(payload), transferred, decoded)
+			}
+		})
+	}
+}
+
+func TestCompressionLimitAppliesToDecodedBytes(t *testing.T) {
+	payload := []byte("# TYPE slurm_jobs gauge\n" + strings.Repeat("slurm_jobs 1\n", 1000))
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(payload)
+	gz.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped.Bytes())
+	}))
+	defer server.Close()
+
+	cfg := testConfig(server.URL, config.EndpointConfig{Name: "jobs", Path: "/metrics/jobs", Enabled: true})
+	cfg.Slurm.Compression = []string{"gzip"}
+	cfg.Slurm.MaxResponseBytes = int64(gzipped.Len()) * 2
+	c := newTestCollector(t, cfg)
+
+	if _, err := c.collectEndpoint(context.Background(), cfg.Endpoints[0]); err == nil || !strings.Contains(err.Error(), "maximum size") {
+		t.Errorf("Expected the decoded payload to exceed the limit, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// DebugScrape is a single scrape of an endpoint captured for troubleshooting:
+// what slurmctld answered and what the exporter made of it
+type DebugScrape struct {
+	Endpoint        string
+	URL             string
+	Status          string
+	Headers         http.Header
+	Raw             string
+	Output          string
+	Series          int
+	FetchDuration   time.Duration
+	ProcessDuration time.Duration
+	Error           string
+}
+
+// DebugEndpoint scrapes an endpoint once from the active Slurm URL, without
+// retries, failover or caching, and returns the raw response next to the
+// transformed output. The transformations are the same as for a regular
+// scrape, so stale series tracking sees it as one.
+func (c *Collector) DebugEndpoint(ctx context.Context, endpoint config.EndpointConfig) DebugScrape {
+	scrape := DebugScrape{
+		Endpoint: endpoint.Name,
+		URL:      c.config.Slurm.URLs[c.upstreams.activeIndex()] + endpoint.Path,
+	}
+
+	start := time.Now()
+	err := c.debugFetch(ctx, &scrape)
+	scrape.FetchDuration = time.Since(start)
+	if err != nil {
+		scrape.Error = err.Error()
+		return scrape
+	}
+
+	start = time.Now()
+	families, err := openmetrics.Parse(strings.NewReader(scrape.Raw))
+	if err != nil {
+		scrape.Error = fmt.Sprintf("failed to parse response: %v", err)
+		return scrape
+	}
+	families = c.process(endpoint, families)
+
+	var output strings.Builder
+	if err := openmetrics.Write(&output, families); err != nil {
+		scrape.Error = fmt.Sprintf("failed to encode metrics: %v", err)
+		return scrape
+	}
+	scrape.ProcessDuration = time.Since(start)
+	scrape.Output = output.String()
+	scrape.Series = countSeries(families)
+	return scrape
+}
+
+// debugFetch requests the URL of the scrape and records the status, headers
+// and decoded body, whatever the status code
+func (c *Collector) debugFetch(ctx context.Context, scrape *DebugScrape) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scrape.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding(c.config.Slurm.Compression))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	scrape.Status = resp.Status
+	scrape.Headers = resp.Header
+
+	decoded, release, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	body := newLimitedBody(decoded, c.config.Slurm.MaxResponseBytes)
+	var raw bytes.Buffer
+	_, err = io.Copy(&raw, body)
+	scrape.Raw = raw.String()
+	if body.exceeded {
+		return fmt.Errorf("response exceeds the maximum size of %d bytes", body.limit)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
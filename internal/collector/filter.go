@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/hostlist"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// nodeLabel is the label identifying the node of a series
+const nodeLabel = "node"
+
+// nodeFilter drops node series outside the allowed hostlists
+type nodeFilter struct {
+	allow *hostlist.Set
+	deny  *hostlist.Set
+}
+
+// newNodeFilter expands the configured hostlists, returning nil when no
+// filter is configured
+func newNodeFilter(cfg config.NodeFilterConfig) (*nodeFilter, error) {
+	if len(cfg.Allow) == 0 && len(cfg.Deny) == 0 {
+		return nil, nil
+	}
+
+	filter := &nodeFilter{}
+	if len(cfg.Allow) > 0 {
+		allow, err := hostlist.NewSet(cfg.Allow...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node_filter.allow: %w", err)
+		}
+		filter.allow = allow
+	}
+
+	deny, err := hostlist.NewSet(cfg.Deny...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node_filter.deny: %w", err)
+	}
+	filter.deny = deny
+
+	return filter, nil
+}
+
+// allowed reports whether the series of a node should be exported
+func (f *nodeFilter) allowed(node string) bool {
+	if f.deny.Contains(node) {
+		return false
+	}
+	return f.allow == nil || f.allow.Contains(node)
+}
+
+// apply removes the samples of filtered nodes. Series without a node label
+// are always kept.
+func (f *nodeFilter) apply(families []*openmetrics.Family) {
+	for _, family := range families {
+		kept := family.Samples[:0]
+		for _, sample := range family.Samples {
+			if node, ok := sample.Label(nodeLabel); ok && !f.allowed(node) {
+				continue
+			}
+			kept = append(kept, sample)
+		}
+		family.Samples = kept
+	}
+}
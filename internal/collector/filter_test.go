@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+func TestNodeFilter(t *testing.T) {
+	filter, err := newNodeFilter(config.NodeFilterConfig{
+		Allow: []string{"c[1-4]", "gpu[01-02]"},
+		Deny:  []string{"c3"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create filter: %v", err)
+	}
+
+	families, err := openmetrics.Parse(strings.NewReader(`# TYPE slurm_node_cpus gauge
+slurm_node_cpus{node="c1"} 2
+slurm_node_cpus{node="c3"} 2
+slurm_node_cpus{node="c5"} 2
+slurm_node_cpus{node="gpu02"} 8
+# TYPE slurm_nodes gauge
+slurm_nodes 4
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	filter.apply(families)
+
+	var nodes []string
+	for _, sample := range families[0].Samples {
+		node, _ := sample.Label("node")
+		nodes = append(nodes, node)
+	}
+	if strings.Join(nodes, ",") != "c1,gpu02" {
+		t.Errorf("Expected c1 and gpu02 to be kept, got %v", nodes)
+	}
+	if len(families[1].Samples) != 1 {
+		t.Error("Expected series without a node label to be kept")
+	}
+}
+
+func TestNodeFilterDisabled(t *testing.T) {
+	filter, err := newNodeFilter(config.NodeFilterConfig{})
+	if err != nil || filter != nil {
+		t.Errorf("Expected no filter without configuration, got %v (%v)", filter, err)
+	}
+}
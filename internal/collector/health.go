@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// healthCacheTTL is how long the outcome of a health check is reused
+const healthCacheTTL = 5 * time.Second
+
+// healthCache shares one in-flight health check between concurrent callers
+// and keeps its outcome for healthCacheTTL, so that readiness probes cannot
+// multiply the load on a struggling slurmctld
+type healthCache struct {
+	flights *coalescer
+
+	mu        sync.Mutex
+	err       error
+	checkedAt time.Time
+}
+
+// newHealthCache creates an empty health check cache
+func newHealthCache() *healthCache {
+	return &healthCache{flights: newCoalescer(func(string) {})}
+}
+
+// cached returns the outcome of the latest check if it is recent enough
+func (h *healthCache) cached(now time.Time) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.checkedAt.IsZero() || now.Sub(h.checkedAt) >= healthCacheTTL {
+		return false, nil
+	}
+	return true, h.err
+}
+
+// store records the outcome of a completed check
+func (h *healthCache) store(err error, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.err = err
+	h.checkedAt = now
+}
+
+// Health checks if the Slurm API is reachable through at least one URL. The
+// URLs are tried active first and the check stops at the first healthy one.
+func (c *Collector) Health(ctx context.Context) error {
+	if ok, err := c.health.cached(time.Now()); ok {
+		return err
+	}
+
+	message, ok, err := c.health.flights.do(ctx, "health", func(ctx context.Context) (string, bool) {
+		err := c.checkFirstHealthy(ctx)
+		if ctx.Err() == nil {
+			c.health.store(err, time.Now())
+		}
+		if err != nil {
+			return err.Error(), false
+		}
+		return "", true
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// checkFirstHealthy checks the URLs, active first, until one answers
+func (c *Collector) checkFirstHealthy(ctx context.Context) error {
+	active := c.upstreams.activeIndex()
+	order := []int{active}
+	for i := range c.config.Slurm.URLs {
+		if i != active {
+			order = append(order, i)
+		}
+	}
+
+	var errs []error
+	for _, index := range order {
+		url := c.config.Slurm.URLs[index]
+		err := c.checkURL(ctx, url)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", url, err))
+	}
+	return errors.Join(errs...)
+}
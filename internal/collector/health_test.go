@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+func TestHealthSharedAndCached(t *testing.T) {
+	var primaryRequests, backupRequests atomic.Int32
+	release := make(chan struct{})
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryRequests.Add(1)
+		<-release
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupRequests.Add(1)
+	}))
+	defer backup.Close()
+
+	cfg := testConfig("", config.EndpointConfig{Name: "jobs", Path: "/metrics/jobs", Enabled: true})
+	cfg.Slurm.URLs = []string{primary.URL, backup.URL}
+	c := newTestCollector(t, cfg)
+
+	// Concurrent probes share a single check
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = c.Health(context.Background())
+		}(i)
+	}
+	for primaryRequests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Probe %d: expected slurmctld to be healthy, got: %v", i, err)
+		}
+	}
+	if n := primaryRequests.Load(); n != 1 {
+		t.Errorf("Expected a single shared check, got %d requests", n)
+	}
+
+	// The check stopped at the healthy primary
+	if n := backupRequests.Load(); n != 0 {
+		t.Errorf("Expected the backup not to be checked, got %d requests", n)
+	}
+
+	// The outcome is reused for a few seconds
+	if err := c.Health(context.Background()); err != nil {
+		t.Errorf("Expected the cached outcome, got: %v", err)
+	}
+	if n := primaryRequests.Load(); n != 1 {
+		t.Errorf("Expected the cached outcome to be served, got %d requests", n)
+	}
+
+	c.health.checkedAt = time.Now().Add(-healthCacheTTL)
+	c.Health(context.Background())
+	if n := primaryRequests.Load(); n != 2 {
+		t.Errorf("Expected a new check once the cache expired, got %d requests", n)
+	}
+}
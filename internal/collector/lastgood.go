@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// snapshot is the processed output of a successful collection
+type snapshot struct {
+	families    []*openmetrics.Family
+	collectedAt time.Time
+}
+
+// lastKnownGood keeps the last successful snapshot of every endpoint
+type lastKnownGood struct {
+	maxAge            time.Duration
+	includeTimestamps bool
+
+	mu        sync.Mutex
+	snapshots map[string]snapshot
+}
+
+// newLastKnownGood creates a store serving snapshots up to maxAge old
+func newLastKnownGood(maxAge time.Duration, includeTimestamps bool) *lastKnownGood {
+	return &lastKnownGood{
+		maxAge:            maxAge,
+		includeTimestamps: includeTimestamps,
+		snapshots:         make(map[string]snapshot),
+	}
+}
+
+// store records the families of a successful collection. The families must
+// not be modified afterwards.
+func (l *lastKnownGood) store(endpoint string, families []*openmetrics.Family, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.snapshots[endpoint] = snapshot{families: families, collectedAt: now}
+}
+
+// load returns a copy of the last snapshot of an endpoint if it is not older
+// than the maximum age, with the collection time attached to every sample
+// when timestamps are enabled
+func (l *lastKnownGood) load(endpoint string, now time.Time) ([]*openmetrics.Family, time.Time, bool) {
+	l.mu.Lock()
+	snap, ok := l.snapshots[endpoint]
+	l.mu.Unlock()
+
+	if !ok || now.Sub(snap.collectedAt) > l.maxAge {
+		return nil, time.Time{}, false
+	}
+
+	if !l.includeTimestamps {
+		return snap.families, snap.collectedAt, true
+	}
+
+	timestamp := snap.collectedAt.UnixMilli()
+	families := make([]*openmetrics.Family, len(snap.families))
+	for i, family := range snap.families {
+		families[i] = family.Clone()
+		for j := range families[i].Samples {
+			families[i].Samples[j].Timestamp = timestamp
+			families[i].Samples[j].HasTimestamp = true
+		}
+	}
+	return families, snap.collectedAt, true
+}
@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+func TestLastKnownGood(t *testing.T) {
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("# TYPE slurm_jobs gauge\nslurm_jobs 3\n"))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(server.URL, config.EndpointConfig{Name: "jobs", Path: "/metrics/jobs", Enabled: true})
+	cfg.LastKnownGood = config.LastKnownGoodConfig{Enabled: true, MaxAge: "1m", IncludeTimestamps: true}
+	c := newTestCollector(t, cfg)
+
+	if _, err := c.CollectAll(context.Background()); err != nil {
+		t.Fatalf("Failed to collect: %v", err)
+	}
+
+	down.Store(true)
+	results, err := c.CollectAll(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to collect: %v", err)
+	}
+	text, ok := results["jobs"]
+	if !ok {
+		t.Fatalf("Expected the last known good payload to be served")
+	}
+	if !strings.HasPrefix(text, "# TYPE slurm_jobs gauge\nslurm_jobs 3 ") {
+		t.Errorf("Expected the cached sample with a timestamp, got %q", text)
+	}
+
+	// Expired snapshots are no longer served
+	c.lastGood.snapshots["jobs"] = snapshot{
+		families:    c.lastGood.snapshots["jobs"].families,
+		collectedAt: time.Now().Add(-2 * time.Minute),
+	}
+	results, _ = c.CollectAll(context.Background())
+	if _, ok := results["jobs"]; ok {
+		t.Errorf("Expected the expired payload not to be served")
+	}
+}
+
+func TestLastKnownGoodWithoutTimestamps(t *testing.T) {
+	store := newLastKnownGood(time.Minute, false)
+	now := time.Now()
+
+	families, err := openmetrics.Parse(strings.NewReader("# TYPE slurm_jobs gauge\nslurm_jobs 3\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	store.store("jobs", families, now)
+
+	loaded, _, ok := store.load("jobs", now.Add(30*time.Second))
+	if !ok {
+		t.Fatalf("Expected the snapshot to be loaded")
+	}
+	if loaded[0].Samples[0].HasTimestamp {
+		t.Errorf("Expected no timestamp on the cached sample")
+	}
+
+	if _, _, ok := store.load("nodes", now); ok {
+		t.Errorf("Expected no snapshot for an unknown endpoint")
+	}
+}
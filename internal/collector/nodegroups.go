@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/hostlist"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// nodeGroupLabel is the label carrying the group of a node
+const nodeGroupLabel = "node_group"
+
+// nodeGroup matches the members of a configured group
+type nodeGroup struct {
+	name    string
+	members *hostlist.Set
+	pattern *regexp.Regexp
+}
+
+// contains reports whether the node belongs to the group
+func (g *nodeGroup) contains(node string) bool {
+	if g.pattern != nil {
+		return g.pattern.MatchString(node)
+	}
+	return g.members.Contains(node)
+}
+
+// nodeGroups assigns nodes to the first matching configured group
+type nodeGroups struct {
+	groups []*nodeGroup
+}
+
+// newNodeGroups compiles the configured groups, returning nil when none are defined
+func newNodeGroups(cfg config.NodeGroupsConfig) (*nodeGroups, error) {
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+
+	g := &nodeGroups{}
+	for _, group := range cfg {
+		compiled := &nodeGroup{name: group.Name}
+		if group.IsRegex() {
+			pattern, err := regexp.Compile(group.AnchoredPattern())
+			if err != nil {
+				return nil, fmt.Errorf("invalid node group %s: %w", group.Name, err)
+			}
+			compiled.pattern = pattern
+		} else {
+			members, err := hostlist.NewSet(group.Members)
+			if err != nil {
+				return nil, fmt.Errorf("invalid node group %s: %w", group.Name, err)
+			}
+			compiled.members = members
+		}
+		g.groups = append(g.groups, compiled)
+	}
+
+	return g, nil
+}
+
+// groupOf returns the name of the first group containing the node
+func (g *nodeGroups) groupOf(node string) (string, bool) {
+	for _, group := range g.groups {
+		if group.contains(node) {
+			return group.name, true
+		}
+	}
+	return "", false
+}
+
+// apply adds the node_group label to every node series and appends one
+// aggregate family per node gauge, summing the values of each group. A
+// slurm_node_group_nodes family counts the nodes seen in each group.
+func (g *nodeGroups) apply(families []*openmetrics.Family) []*openmetrics.Family {
+	nodesPerGroup := make(map[string]map[string]bool)
+	var aggregates []*openmetrics.Family
+
+	for _, family := range families {
+		sums := make(map[string]float64)
+		var order []string
+
+		for i := range family.Samples {
+			sample := &family.Samples[i]
+			node, ok := sample.Label(nodeLabel)
+			if !ok {
+				continue
+			}
+			group, ok := g.groupOf(node)
+			if !ok {
+				continue
+			}
+			sample.SetLabel(nodeGroupLabel, group)
+
+			if nodesPerGroup[group] == nil {
+				nodesPerGroup[group] = make(map[string]bool)
+			}
+			nodesPerGroup[group][node] = true
+
+			if _, seen := sums[group]; !seen {
+				order = append(order, group)
+			}
+			sums[group] += sample.Value
+		}
+
+		if len(order) == 0 || (family.Type != openmetrics.TypeGauge && family.Type != openmetrics.TypeUntyped) {
+			continue
+		}
+
+		aggregate := &openmetrics.Family{
+			Name: nodeGroupFamilyName(family.Name),
+			Help: fmt.Sprintf("Sum per node group of: %s", family.Help),
+			Type: openmetrics.TypeGauge,
+		}
+		for _, group := range order {
+			aggregate.Samples = append(aggregate.Samples, openmetrics.Sample{
+				Name:   aggregate.Name,
+				Labels: []openmetrics.Label{{Name: nodeGroupLabel, Value: group}},
+				Value:  sums[group],
+			})
+		}
+		aggregates = append(aggregates, aggregate)
+	}
+
+	if len(nodesPerGroup) == 0 {
+		return families
+	}
+
+	nodes := &openmetrics.Family{
+		Name: "slurm_node_group_nodes",
+		Help: "Number of nodes reported in the node group",
+		Type: openmetrics.TypeGauge,
+	}
+	for _, group := range g.groups {
+		if members, ok := nodesPerGroup[group.name]; ok {
+			nodes.Samples = append(nodes.Samples, openmetrics.Sample{
+				Name:   nodes.Name,
+				Labels: []openmetrics.Label{{Name: nodeGroupLabel, Value: group.name}},
+				Value:  float64(len(members)),
+			})
+		}
+	}
+
+	return append(append(families, aggregates...), nodes)
+}
+
+// nodeGroupFamilyName derives the aggregate family name, e.g.
+// slurm_node_cpus_alloc becomes slurm_node_group_cpus_alloc
+func nodeGroupFamilyName(name string) string {
+	if rest, ok := strings.CutPrefix(name, "slurm_node_"); ok {
+		return "slurm_node_group_" + rest
+	}
+	return "slurm_node_group_" + strings.TrimPrefix(name, "slurm_")
+}
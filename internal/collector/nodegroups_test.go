@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+func TestNodeGroups(t *testing.T) {
+	groups, err := newNodeGroups(config.NodeGroupsConfig{
+		{Name: "a100", Members: "gpu[01-02]"},
+		{Name: "compute", Members: "/^c[0-9]+$/"},
+		{Name: "v100", Members: "/gpu0[1-4]/"},
+		{Name: "other", Members: "/.*gpu.*/"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create node groups: %v", err)
+	}
+
+	families, err := openmetrics.Parse(strings.NewReader(`# HELP slurm_node_cpus Total number of cpus in the node
+# TYPE slurm_node_cpus gauge
+slurm_node_cpus{node="c1"} 2
+slurm_node_cpus{node="c2"} 4
+slurm_node_cpus{node="gpu01"} 8
+slurm_node_cpus{node="login1"} 1
+slurm_node_cpus{node="gpu03"} 4
+slurm_node_cpus{node="xgpu012"} 16
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	families = groups.apply(families)
+
+	var out strings.Builder
+	if err := openmetrics.Write(&out, families); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	for _, want := range []string{
+		`slurm_node_cpus{node="c1",node_group="compute"} 2`,
+		`slurm_node_cpus{node="gpu01",node_group="a100"} 8`,
+		`slurm_node_cpus{node="login1"} 1`,
+		`slurm_node_cpus{node="gpu03",node_group="v100"} 4`,
+		`slurm_node_cpus{node="xgpu012",node_group="other"} 16`,
+		"# HELP slurm_node_group_cpus Sum per node group of: Total number of cpus in the node",
+		`slurm_node_group_cpus{node_group="compute"} 6`,
+		`slurm_node_group_cpus{node_group="a100"} 8`,
+		`slurm_node_group_nodes{node_group="a100"} 1`,
+		`slurm_node_group_nodes{node_group="compute"} 2`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestNodeGroupsMaxSeries(t *testing.T) {
+	endpoint := config.EndpointConfig{Name: "nodes", Path: "/metrics/nodes", Enabled: true, MaxSeries: 5}
+	cfg := testConfig("http://localhost:6817", endpoint)
+	cfg.NodeGroups = config.NodeGroupsConfig{
+		{Name: "compute", Members: "c[1-2]"},
+		{Name: "bigmem", Members: "/c[3-4]/"},
+	}
+	c := newTestCollector(t, cfg)
+
+	families, err := openmetrics.Parse(strings.NewReader(`# TYPE slurm_node_cpus gauge
+slurm_node_cpus{node="c1"} 1
+slurm_node_cpus{node="c2"} 2
+slurm_node_cpus{node="c3"} 3
+slurm_node_cpus{node="c4"} 4
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	// The node series, the per-group sums and the node counts share the
+	// budget
+	families = c.process(cfg.Endpoints[0], families)
+	if count := countSeries(families); count != 5 {
+		t.Errorf("Expected max_series to cap the node group series too, got %d series", count)
+	}
+
+	// The group sums were computed from the nodes before they were folded
+	total := 0.0
+	for _, family := range families {
+		if family.Name == "slurm_node_group_cpus" {
+			for _, sample := range family.Samples {
+				total += sample.Value
+			}
+		}
+	}
+	if total != 10 {
+		t.Errorf("Expected the group sums to add up to 10, got %v", total)
+	}
+}
@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/backoff"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+// errCircuitOpen is returned while the circuit breaker of an endpoint is open
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// transientError marks failures worth retrying, such as refused connections
+// or 5xx responses during a slurmctld failover
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// bodyError marks failures raised while consuming a response, such as parse
+// errors or an exceeded size limit. slurmctld did answer, so they say nothing
+// about its availability.
+type bodyError struct {
+	err error
+}
+
+func (e *bodyError) Error() string { return e.err.Error() }
+func (e *bodyError) Unwrap() error { return e.err }
+
+// isBodyError reports whether err was raised while consuming a response
+func isBodyError(err error) bool {
+	var body *bodyError
+	return errors.As(err, &body)
+}
+
+// isTransient reports whether err may succeed when retried
+func isTransient(err error) bool {
+	var transient *transientError
+	return errors.As(err, &transient)
+}
+
+// isTransientStatus reports whether an HTTP status code may succeed when retried
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryPolicy computes the jittered exponential backoff between attempts
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// newRetryPolicy creates a retry policy from the configuration
+func newRetryPolicy(cfg config.RetryConfig) (retryPolicy, error) {
+	initial, err := time.ParseDuration(cfg.InitialBackoff)
+	if err != nil {
+		return retryPolicy{}, fmt.Errorf("invalid initial_backoff: %w", err)
+	}
+	maximum, err := time.ParseDuration(cfg.MaxBackoff)
+	if err != nil {
+		return retryPolicy{}, fmt.Errorf("invalid max_backoff: %w", err)
+	}
+
+	attempts := cfg.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	return retryPolicy{maxAttempts: attempts, initialBackoff: initial, maxBackoff: maximum}, nil
+}
+
+// backoff returns the delay before the given retry (starting at 1) using
+// full jitter: a random duration up to the capped exponential delay
+func (p retryPolicy) backoff(retry int) time.Duration {
+	return backoff.Jittered(p.initialBackoff, p.maxBackoff, retry)
+}
+
+// wait sleeps for the backoff of the given retry. It returns false without
+// sleeping when the context deadline would expire before the next attempt.
+func (p retryPolicy) wait(ctx context.Context, retry int) bool {
+	delay := p.backoff(retry)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Circuit breaker states, exported as the value of the state gauge
+const (
+	breakerClosed   = 0
+	breakerOpen     = 1
+	breakerHalfOpen = 2
+)
+
+// circuitBreaker stops querying an endpoint after consecutive failures
+type circuitBreaker struct {
+	threshold    int
+	openDuration time.Duration
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+}
+
+// newCircuitBreaker creates a closed circuit breaker
+func newCircuitBreaker(threshold int, openDuration time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, openDuration: openDuration}
+}
+
+// allow reports whether a request may be sent. Once the open duration has
+// elapsed a single trial request is allowed in the half-open state.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.openDuration {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// A trial request is already in flight
+		return false
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of a request and returns the new state
+func (b *circuitBreaker) record(success bool, now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.state = breakerClosed
+		b.failures = 0
+		return b.state
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = now
+	}
+	return b.state
+}
+
+// abandon releases a half-open trial whose outcome says nothing about the
+// upstream, so that the next request is let through as a new trial. The
+// failure count is left unchanged.
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+	}
+}
+
+// currentState returns the state of the breaker
+func (b *circuitBreaker) currentState() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+func TestRetryTransientFailures(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			http.Error(w, "failover in progress", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("# TYPE slurm_jobs gauge\nslurm_jobs 3\n"))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(server.URL, config.EndpointConfig{Name: "jobs", Path: "/metrics/jobs", Enabled: true})
+	cfg.Slurm.Retry = config.RetryConfig{MaxAttempts: 3, InitialBackoff: "1ms", MaxBackoff: "5ms"}
+	c := newTestCollector(t, cfg)
+
+	text, err := c.collectEndpoint(context.Background(), cfg.Endpoints[0])
+	if err != nil {
+		t.Fatalf("Expected the third attempt to succeed, got: %v", err)
+	}
+	if text != "# TYPE slurm_jobs gauge\nslurm_jobs 3\n" {
+		t.Errorf("Unexpected metrics %q", text)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests.Load())
+	}
+}
+
+func TestRetrySkipsPermanentFailures(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	cfg := testConfig(server.URL, config.EndpointConfig{Name: "jobs", Path: "/metrics/jobs", Enabled: true})
+	cfg.Slurm.Retry = config.RetryConfig{MaxAttempts: 5, InitialBackoff: "1ms", MaxBackoff: "5ms"}
+	c := newTestCollector(t, cfg)
+
+	if _, err := c.collectEndpoint(context.Background(), cfg.Endpoints[0]); err == nil {
+		t.Fatal("Expected an error for a 404 response")
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected a single request, got %d", requests.Load())
+	}
+}
+
+func TestRetryRespectsDeadline(t *testing.T) {
+	policy := retryPolicy{maxAttempts: 5, initialBackoff: time.Second, maxBackoff: time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// The backoff may be shorter than the deadline due to jitter, so only a
+	// refusal must return quickly
+	start := time.Now()
+	if policy.wait(ctx, 1) && time.Since(start) > 50*time.Millisecond {
+		t.Error("Expected wait not to outlive the context deadline")
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := testConfig(server.URL, config.EndpointConfig{Name: "nodes", Path: "/metrics/nodes", Enabled: true})
+	cfg.Slurm.CircuitBreaker = config.CircuitBreakerConfig{Enabled: true, FailureThreshold: 2, OpenDuration: "1h"}
+	c := newTestCollector(t, cfg)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.collectEndpoint(context.Background(), cfg.Endpoints[0]); err == nil {
+			t.Fatal("Expected an error from the failing endpoint")
+		}
+	}
+
+	_, err := c.collectEndpoint(context.Background(), cfg.Endpoints[0])
+	if !errors.Is(err, errCircuitOpen) {
+		t.Errorf("Expected the circuit breaker to be open, got: %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("Expected 2 upstream requests, got %d", requests.Load())
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	breaker := newCircuitBreaker(1, time.Minute)
+	now := time.Now()
+
+	breaker.record(false, now)
+	if breaker.allow(now.Add(time.Second)) {
+		t.Error("Expected the breaker to reject requests while open")
+	}
+
+	if !breaker.allow(now.Add(2 * time.Minute)) {
+		t.Fatal("Expected a trial request after the open duration")
+	}
+	if breaker.allow(now.Add(2 * time.Minute)) {
+		t.Error("Expected a single trial request while half-open")
+	}
+
+	if state := breaker.record(true, now.Add(2*time.Minute)); state != breakerClosed {
+		t.Errorf("Expected the breaker to close after a successful trial, got state %d", state)
+	}
+}
+
+func TestCircuitBreakerIgnoresCancelledScrapes(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	cfg := testConfig(server.URL, config.EndpointConfig{Name: "nodes", Path: "/metrics/nodes", Enabled: true})
+	cfg.Slurm.CircuitBreaker = config.CircuitBreakerConfig{Enabled: true, FailureThreshold: 1, OpenDuration: "1h"}
+	c := newTestCollector(t, cfg)
+
+	// The scraping client goes away while slurmctld is answering
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		_, err := c.collectEndpoint(ctx, cfg.Endpoints[0])
+		cancel()
+		if err == nil || errors.Is(err, errCircuitOpen) {
+			t.Fatalf("Expected the cancelled scrape to fail upstream, got: %v", err)
+		}
+	}
+
+	if state := c.breakers["nodes"].currentState(); state != breakerClosed {
+		t.Errorf("Expected the breaker to stay closed, got state %d", state)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("Expected every scrape to reach the upstream, got %d requests", requests.Load())
+	}
+}
+
+func TestCircuitBreakerIgnoresBodyErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("slurm_jobs{ not a sample\n"))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(server.URL, config.EndpointConfig{Name: "jobs", Path: "/metrics/jobs", Enabled: true})
+	cfg.Slurm.CircuitBreaker = config.CircuitBreakerConfig{Enabled: true, FailureThreshold: 1, OpenDuration: "1h"}
+	c := newTestCollector(t, cfg)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.collectEndpoint(context.Background(), cfg.Endpoints[0]); err == nil || errors.Is(err, errCircuitOpen) {
+			t.Fatalf("Expected a parse error, got: %v", err)
+		}
+	}
+	if state := c.breakers["jobs"].currentState(); state != breakerClosed {
+		t.Errorf("Expected the breaker to stay closed, got state %d", state)
+	}
+}
+
+func TestCircuitBreakerAbandonedTrial(t *testing.T) {
+	breaker := newCircuitBreaker(1, time.Minute)
+	now := time.Now()
+
+	breaker.record(false, now)
+	if !breaker.allow(now.Add(2 * time.Minute)) {
+		t.Fatal("Expected a trial request after the open duration")
+	}
+	breaker.abandon()
+	if !breaker.allow(now.Add(2 * time.Minute)) {
+		t.Error("Expected a new trial once the previous one was abandoned")
+	}
+}
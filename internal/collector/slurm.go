@@ -2,26 +2,44 @@ package collector
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/enrichment"
 	"github.com/sckyzo/slurm_prometheus_exporter/internal/metrics"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/topology"
 )
 
 // Collector is responsible for collecting metrics from Slurm
 type Collector struct {
-	config   *config.Config
-	client   *http.Client
-	registry *metrics.Registry
-	logger   *slog.Logger
+	config          *config.Config
+	client          *http.Client
+	registry        *metrics.Registry
+	logger          *slog.Logger
+	typeCorrections map[string]string
+	stale           *staleTracker
+	enricher        *enrichment.Enricher
+	topology        *topology.Enricher
+	nodeFilter      *nodeFilter
+	nodeGroups      *nodeGroups
+	retry           retryPolicy
+	breakers        map[string]*circuitBreaker
+	upstreams       *upstreamPool
+	lastGood        *lastKnownGood
+	status          *statusTracker
+	summary         *summaryTracker
+	labelNames      []string
+	flights         *coalescer
+	health          *healthCache
 }
 
 // NewCollector creates a new Slurm metrics collector
@@ -31,190 +49,572 @@ func NewCollector(cfg *config.Config, registry *metrics.Registry, logger *slog.L
 		return nil, fmt.Errorf("invalid timeout configuration: %w", err)
 	}
 
-	// Create HTTP client with optional TLS insecure skip verify
+	// Create HTTP client with optional TLS insecure skip verify. Responses are
+	// decompressed by the collector according to the configured encodings.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableCompression = true
 	httpClient := &http.Client{
-		Timeout: timeout,
+		Timeout:   timeout,
+		Transport: transport,
 	}
 
 	// Configure TLS if needed
 	if cfg.Slurm.TLSInsecureVerify {
-		httpClient.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
 		}
 		logger.Warn("TLS certificate verification is disabled - this is insecure and should only be used for testing")
 	}
 
-	return &Collector{
-		config:   cfg,
-		client:   httpClient,
-		registry: registry,
-		logger:   logger,
-	}, nil
+	c := &Collector{
+		config:          cfg,
+		client:          httpClient,
+		registry:        registry,
+		logger:          logger,
+		typeCorrections: buildTypeCorrections(cfg.TypeCorrection.Overrides),
+		status:          newStatusTracker(),
+		summary:         newSummaryTracker(),
+		health:          newHealthCache(),
+	}
+
+	// Sort the custom label names so the output is stable between scrapes
+	for name := range cfg.Labels {
+		c.labelNames = append(c.labelNames, name)
+	}
+	sort.Strings(c.labelNames)
+
+	// Share in-flight collections between concurrent scrapes if enabled
+	if cfg.Server.CoalesceScrapes {
+		c.flights = newCoalescer(func(endpoint string) {
+			registry.CoalescedRequests.WithLabelValues(endpoint).Inc()
+		})
+	}
+
+	// Fail over between the configured slurmctld URLs
+	recheckInterval, err := time.ParseDuration(cfg.Slurm.PrimaryRecheckInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid primary recheck interval: %w", err)
+	}
+	c.upstreams = newUpstreamPool(cfg.Slurm.URLs, recheckInterval)
+	c.updateActiveUpstream()
+
+	// Retry transient upstream failures
+	retry, err := newRetryPolicy(cfg.Slurm.Retry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry configuration: %w", err)
+	}
+	c.retry = retry
+
+	// Stop querying endpoints that keep failing if enabled
+	if cfg.Slurm.CircuitBreaker.Enabled {
+		openDuration, err := time.ParseDuration(cfg.Slurm.CircuitBreaker.OpenDuration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid circuit breaker configuration: %w", err)
+		}
+		c.breakers = make(map[string]*circuitBreaker, len(cfg.Endpoints))
+		for _, endpoint := range cfg.Endpoints {
+			c.breakers[endpoint.Name] = newCircuitBreaker(cfg.Slurm.CircuitBreaker.FailureThreshold, openDuration)
+			registry.CircuitBreakerState.WithLabelValues(endpoint.Name).Set(breakerClosed)
+		}
+	}
+
+	// Serve the last successful payload on upstream failure if enabled
+	if cfg.LastKnownGood.Enabled {
+		maxAge, err := time.ParseDuration(cfg.LastKnownGood.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid last known good configuration: %w", err)
+		}
+		c.lastGood = newLastKnownGood(maxAge, cfg.LastKnownGood.IncludeTimestamps)
+	}
+
+	// Restrict node series to the configured hostlists
+	filter, err := newNodeFilter(cfg.NodeFilter)
+	if err != nil {
+		return nil, err
+	}
+	c.nodeFilter = filter
+
+	// Label node series with their configured group
+	groups, err := newNodeGroups(cfg.NodeGroups)
+	if err != nil {
+		return nil, err
+	}
+	c.nodeGroups = groups
+
+	// Remember vanished series if enabled
+	if cfg.StaleSeries.Enabled {
+		gracePeriod, err := cfg.GetStaleGracePeriod()
+		if err != nil {
+			return nil, fmt.Errorf("invalid stale series configuration: %w", err)
+		}
+		c.stale = newStaleTracker(gracePeriod)
+	}
+
+	// Join username and account labels against the mapping if enabled
+	if cfg.Enrichment.Enabled {
+		enricher, err := enrichment.New(cfg.Enrichment, logger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enrichment configuration: %w", err)
+		}
+		c.enricher = enricher
+	}
+
+	// Add switch, rack and chassis labels to node series if enabled
+	if cfg.Topology.Enabled {
+		enricher, err := topology.NewEnricher(cfg.Topology, logger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid topology configuration: %w", err)
+		}
+		c.topology = enricher
+	}
+
+	return c, nil
+}
+
+// Close stops the background work of the collector, such as LDAP lookups
+func (c *Collector) Close() {
+	if c.enricher != nil {
+		c.enricher.Close()
+	}
 }
 
 // CollectAll collects metrics from all enabled Slurm endpoints
 func (c *Collector) CollectAll(ctx context.Context) (map[string]string, error) {
-	enabledEndpoints := c.config.GetEnabledEndpoints()
 	results := make(map[string]string)
 
-	for _, endpoint := range enabledEndpoints {
-		c.logger.Debug("collecting metrics from endpoint",
-			"name", endpoint.Name,
-			"path", endpoint.Path)
+	for _, endpoint := range c.config.GetEnabledEndpoints() {
+		var buffer strings.Builder
+		if c.collect(ctx, endpoint, &buffer, false) {
+			results[endpoint.Name] = buffer.String()
+		}
+	}
+
+	return results, nil
+}
 
-		var timer *prometheus.Timer
-		if c.registry.ScrapeDuration != nil {
-			timer = prometheus.NewTimer(c.registry.ScrapeDuration.WithLabelValues(endpoint.Name))
+// CollectFamilies collects the given endpoints through the same pipeline as
+// WriteEndpoints and returns the parsed families, for output formats other
+// than the Prometheus text format
+func (c *Collector) CollectFamilies(ctx context.Context, endpoints []config.EndpointConfig) ([]*openmetrics.Family, error) {
+	var families []*openmetrics.Family
+	for _, endpoint := range endpoints {
+		var buffer strings.Builder
+		if !c.collect(ctx, endpoint, &buffer, false) {
+			continue
 		}
-		metrics, err := c.collectEndpoint(ctx, endpoint)
-		if timer != nil {
-			timer.ObserveDuration()
+		parsed, err := openmetrics.Parse(strings.NewReader(buffer.String()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse metrics of endpoint %s: %w", endpoint.Name, err)
+		}
+		families = append(families, parsed...)
+	}
+	return families, nil
+}
+
+// WriteAll collects metrics from all enabled Slurm endpoints and writes them
+// to w as each endpoint completes
+func (c *Collector) WriteAll(ctx context.Context, w io.Writer) error {
+	return c.WriteEndpoints(ctx, w, c.config.GetEnabledEndpoints())
+}
+
+// WriteEndpoints collects metrics from the given endpoints and writes them to
+// w as each endpoint completes. Endpoints that need no whole-payload
+// transformation are streamed family by family from the upstream response.
+func (c *Collector) WriteEndpoints(ctx context.Context, w io.Writer, endpoints []config.EndpointConfig) error {
+	bw := bufio.NewWriter(w)
+
+	for _, endpoint := range endpoints {
+		c.collect(ctx, endpoint, bw, c.streamable(endpoint))
+		if err := bw.Flush(); err != nil {
+			return fmt.Errorf("failed to write metrics: %w", err)
 		}
+	}
+
+	return nil
+}
+
+// collect writes the metrics of an endpoint to w, sharing the collection
+// with concurrent scrapes when coalescing is enabled. It reports whether
+// metrics, fresh or last known good, were written.
+func (c *Collector) collect(ctx context.Context, endpoint config.EndpointConfig, w io.Writer, stream bool) bool {
+	if c.flights == nil {
+		return c.collectAndRecord(ctx, endpoint, w, stream)
+	}
+
+	text, ok, err := c.flights.do(ctx, endpoint.Name, func(ctx context.Context) (string, bool) {
+		var buffer strings.Builder
+		ok := c.collectAndRecord(ctx, endpoint, &buffer, stream)
+		return buffer.String(), ok
+	})
+	if err != nil {
+		c.logger.Debug("scrape cancelled while waiting for endpoint",
+			"endpoint", endpoint.Name,
+			"error", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	io.WriteString(w, text)
+	return true
+}
+
+// collectAndRecord writes the metrics of an endpoint to w and records the
+// outcome. It reports whether metrics, fresh or last known good, were written.
+func (c *Collector) collectAndRecord(ctx context.Context, endpoint config.EndpointConfig, w io.Writer, stream bool) bool {
+	c.logger.Debug("collecting metrics from endpoint",
+		"name", endpoint.Name,
+		"path", endpoint.Path,
+		"streaming", stream)
+
+	start := time.Now()
+	err := c.writeEndpoint(ctx, endpoint, w, stream)
+	duration := time.Since(start)
+	if c.registry.ScrapeDuration != nil {
+		c.registry.ScrapeDuration.WithLabelValues(endpoint.Name).Observe(duration.Seconds())
+	}
+
+	if err != nil {
+		c.logger.Error("failed to collect metrics from endpoint",
+			"endpoint", endpoint.Name,
+			"error", err)
+		c.registry.ScrapeSuccess.WithLabelValues(endpoint.Name).Set(0)
+		c.registry.ScrapeErrors.WithLabelValues(endpoint.Name).Inc()
+
+		// Serve the last successful payload instead of dropping the endpoint
+		served := c.writeLastKnownGood(endpoint.Name, w)
+		c.status.record(endpoint.Name, err, served, duration, time.Now())
+		return served
+	}
+
+	c.status.record(endpoint.Name, nil, false, duration, time.Now())
+	c.registry.ScrapeSuccess.WithLabelValues(endpoint.Name).Set(1)
+	if c.lastGood != nil {
+		c.registry.EndpointStale.WithLabelValues(endpoint.Name).Set(0)
+	}
+	return true
+}
+
+// writeLastKnownGood writes the last successful payload of an endpoint if
+// last-known-good serving is enabled and the payload is recent enough
+func (c *Collector) writeLastKnownGood(endpoint string, w io.Writer) bool {
+	if c.lastGood == nil {
+		return false
+	}
+
+	families, collectedAt, ok := c.lastGood.load(endpoint, time.Now())
+	if !ok {
+		c.registry.EndpointStale.WithLabelValues(endpoint).Set(0)
+		return false
+	}
 
+	if err := openmetrics.Write(w, families); err != nil {
+		c.logger.Error("failed to write last known good metrics", "endpoint", endpoint, "error", err)
+		return false
+	}
+
+	c.logger.Warn("serving last known good metrics",
+		"endpoint", endpoint,
+		"age", time.Since(collectedAt).Round(time.Second))
+	c.registry.EndpointStale.WithLabelValues(endpoint).Set(1)
+	return true
+}
+
+// collectEndpoint collects metrics from a single Slurm endpoint as raw text
+func (c *Collector) collectEndpoint(ctx context.Context, endpoint config.EndpointConfig) (string, error) {
+	var buffer strings.Builder
+	if err := c.writeEndpoint(ctx, endpoint, &buffer, false); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// writeEndpoint collects a single Slurm endpoint and writes its metrics to w,
+// either streaming the families or processing the whole payload at once
+func (c *Collector) writeEndpoint(ctx context.Context, endpoint config.EndpointConfig, w io.Writer, stream bool) error {
+	if stream {
+		return c.streamEndpoint(ctx, endpoint, w)
+	}
+
+	var families []*openmetrics.Family
+	err := c.fetchWithRetry(ctx, endpoint, func(body io.Reader) error {
+		parsed, err := openmetrics.Parse(body)
 		if err != nil {
-			c.logger.Error("failed to collect metrics from endpoint",
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+		families = parsed
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	families = c.process(endpoint, families)
+	c.summary.observe(families, time.Now())
+	if c.lastGood != nil {
+		c.lastGood.store(endpoint.Name, families, time.Now())
+	}
+
+	if err := openmetrics.Write(w, families); err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+	return nil
+}
+
+// fetchWithRetry fetches an endpoint, retrying transient failures with backoff
+// while the scrape deadline allows it, and guarded by the circuit breaker.
+// The response body is handed to consume.
+func (c *Collector) fetchWithRetry(ctx context.Context, endpoint config.EndpointConfig, consume func(io.Reader) error) error {
+	breaker := c.breakers[endpoint.Name]
+	if breaker != nil && !breaker.allow(time.Now()) {
+		return errCircuitOpen
+	}
+	if breaker != nil {
+		c.registry.CircuitBreakerState.WithLabelValues(endpoint.Name).Set(float64(breaker.currentState()))
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = c.fetch(ctx, endpoint, consume)
+		if err == nil || !isTransient(err) || attempt >= c.retry.maxAttempts {
+			break
+		}
+		if !c.retry.wait(ctx, attempt) {
+			break
+		}
+
+		c.logger.Debug("retrying endpoint after transient failure",
+			"endpoint", endpoint.Name,
+			"attempt", attempt+1,
+			"error", err)
+		c.registry.UpstreamRetries.WithLabelValues(endpoint.Name).Inc()
+	}
+
+	// Cancelled scrapes and unusable bodies are not upstream failures
+	if breaker != nil && err != nil && (ctx.Err() != nil || isBodyError(err)) {
+		breaker.abandon()
+		c.registry.CircuitBreakerState.WithLabelValues(endpoint.Name).Set(float64(breaker.currentState()))
+		return err
+	}
+	if breaker != nil {
+		state := breaker.record(err == nil, time.Now())
+		c.registry.CircuitBreakerState.WithLabelValues(endpoint.Name).Set(float64(state))
+		if state == breakerOpen && err != nil {
+			c.logger.Warn("circuit breaker opened for endpoint",
 				"endpoint", endpoint.Name,
 				"error", err)
-			c.registry.ScrapeSuccess.WithLabelValues(endpoint.Name).Set(0)
-			c.registry.ScrapeErrors.WithLabelValues(endpoint.Name).Inc()
-			continue
+		}
+	}
+
+	return err
+}
+
+// fetch requests an endpoint from the active upstream, failing over to the
+// other configured URLs on transient errors
+func (c *Collector) fetch(ctx context.Context, endpoint config.EndpointConfig, consume func(io.Reader) error) error {
+	var lastErr error
+	for _, index := range c.upstreams.order(time.Now()) {
+		url := c.config.Slurm.URLs[index]
+		err := c.fetchURL(ctx, endpoint.Name, url+endpoint.Path, consume)
+		c.recordUpstream(ctx, url, err)
+		if err == nil {
+			if c.upstreams.markActive(index, time.Now()) {
+				c.logger.Warn("switched active slurm upstream", "url", url)
+				c.updateActiveUpstream()
+			}
+			return nil
 		}
 
-		c.registry.ScrapeSuccess.WithLabelValues(endpoint.Name).Set(1)
-		results[endpoint.Name] = metrics
+		lastErr = err
+		if !isTransient(err) {
+			return err
+		}
+		c.logger.Debug("slurm upstream failed",
+			"url", url,
+			"endpoint", endpoint.Name,
+			"error", err)
 	}
 
-	return results, nil
+	return lastErr
 }
 
-// collectEndpoint collects metrics from a single Slurm endpoint as raw text
-func (c *Collector) collectEndpoint(ctx context.Context, endpoint config.EndpointConfig) (string, error) {
-	url := c.config.Slurm.URL + endpoint.Path
+// recordUpstream records the outcome of a scrape of a Slurm URL. Only
+// transient errors mark it unreachable: any other answer shows that it is up,
+// and cancelled scrapes say nothing about it.
+func (c *Collector) recordUpstream(ctx context.Context, url string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil && !isTransient(err) {
+		err = nil
+	}
+	c.status.upstream(url, err, time.Now())
+}
+
+// updateActiveUpstream sets the active upstream gauge to 1 for the URL in use
+func (c *Collector) updateActiveUpstream() {
+	active := c.upstreams.activeIndex()
+	for i, url := range c.config.Slurm.URLs {
+		value := 0.0
+		if i == active {
+			value = 1
+		}
+		c.registry.ActiveUpstream.WithLabelValues(url).Set(value)
+	}
+}
 
+// fetchURL performs a single request and hands the decompressed response
+// body, bounded by the maximum response size, to consume
+func (c *Collector) fetchURL(ctx context.Context, endpoint, url string, consume func(io.Reader) error) error {
 	c.logger.Debug("fetching metrics from URL", "url", url)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("Accept-Encoding", acceptEncoding(c.config.Slurm.Compression))
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch metrics: %w", err)
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to fetch metrics: %w", err)
+		}
+		return &transientError{fmt.Errorf("failed to fetch metrics: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		err := fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		if isTransientStatus(resp.StatusCode) {
+			return &transientError{err}
+		}
+		return err
 	}
 
-	// Read the metrics as raw text
-	var buffer bytes.Buffer
-	if _, err := io.Copy(&buffer, resp.Body); err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+	transferred := &countingReader{reader: resp.Body}
+	decoded, release, err := decodeBody(transferred, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return &bodyError{err}
 	}
+	defer release()
 
-	// Add custom labels to each metric line
-	metricsText := c.addCustomLabels(buffer.String())
+	// The size limit applies to the decoded payload to guard against
+	// compression bombs
+	counted := &countingReader{reader: decoded}
+	body := newLimitedBody(counted, c.config.Slurm.MaxResponseBytes)
+	err = consume(body)
 
-	return metricsText, nil
+	c.registry.UpstreamTransferredBytes.WithLabelValues(endpoint).Add(float64(transferred.n))
+	c.registry.UpstreamDecodedBytes.WithLabelValues(endpoint).Add(float64(counted.n))
+
+	if err != nil {
+		if body.exceeded {
+			return &bodyError{fmt.Errorf("response exceeds the maximum size of %d bytes", body.limit)}
+		}
+		return &bodyError{err}
+	}
+
+	return nil
 }
 
-// addCustomLabels adds configured custom labels to all metric lines
-func (c *Collector) addCustomLabels(metricsText string) string {
-	if len(c.config.Labels) == 0 {
-		return metricsText
+// process applies the transformation pipeline to the families of an endpoint
+func (c *Collector) process(endpoint config.EndpointConfig, families []*openmetrics.Family) []*openmetrics.Family {
+	if c.nodeFilter != nil {
+		c.nodeFilter.apply(families)
 	}
 
-	// Build the labels string to append
-	var labelsBuilder strings.Builder
-	first := true
-	for key, value := range c.config.Labels {
-		if !first {
-			labelsBuilder.WriteString(",")
-		}
-		labelsBuilder.WriteString(key)
-		labelsBuilder.WriteString("=\"")
-		labelsBuilder.WriteString(value)
-		labelsBuilder.WriteString("\"")
-		first = false
+	c.correctTypes(families)
+
+	if c.stale != nil {
+		families = c.stale.apply(endpoint.Name, families, time.Now())
 	}
-	labelsStr := labelsBuilder.String()
 
-	// Parse each line and add labels
-	var result strings.Builder
-	scanner := bufio.NewScanner(strings.NewReader(metricsText))
-	for scanner.Scan() {
-		line := scanner.Text()
+	// Node group aggregates are computed from the nodes before they are
+	// folded and count against max_series
+	if c.nodeGroups != nil {
+		families = c.nodeGroups.apply(families)
+	}
 
-		// Skip comments and empty lines
-		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
-			result.WriteString(line)
-			result.WriteString("\n")
-			continue
-		}
+	if folded := limitCardinality(endpoint, families); folded > 0 {
+		c.logger.Debug("cardinality limit reached",
+			"endpoint", endpoint.Name,
+			"series_aggregated", folded)
+		c.registry.SeriesDropped.WithLabelValues(endpoint.Name).Add(float64(folded))
+	}
 
-		// Parse metric name and value
-		// Format: metric_name{labels} value timestamp
-		// or: metric_name value
-		parts := strings.Fields(line)
-		if len(parts) < 2 {
-			result.WriteString(line)
-			result.WriteString("\n")
-			continue
-		}
+	if c.enricher != nil {
+		c.enricher.Enrich(families)
+	}
+	if c.topology != nil {
+		c.topology.Enrich(families)
+	}
 
-		metricPart := parts[0]
-		rest := strings.Join(parts[1:], " ")
+	c.registry.SeriesCount.WithLabelValues(endpoint.Name).Set(float64(countSeries(families)))
 
-		// Check if there are already labels
-		if strings.Contains(metricPart, "{") {
-			// Add our labels to existing ones
-			idx := strings.Index(metricPart, "{")
-			name := metricPart[:idx]
-			existingLabels := metricPart[idx+1 : len(metricPart)-1]
-			result.WriteString(name)
-			result.WriteString("{")
-			result.WriteString(existingLabels)
-			result.WriteString(",")
-			result.WriteString(labelsStr)
-			result.WriteString("} ")
-		} else {
-			// Add labels to metric without any
-			result.WriteString(metricPart)
-			result.WriteString("{")
-			result.WriteString(labelsStr)
-			result.WriteString("} ")
-		}
+	// Add custom labels to each metric sample
+	c.addCustomLabels(families)
 
-		result.WriteString(rest)
-		result.WriteString("\n")
-	}
+	return families
+}
 
-	return result.String()
+// countSeries returns the number of samples across all families
+func countSeries(families []*openmetrics.Family) int {
+	count := 0
+	for _, family := range families {
+		count += len(family.Samples)
+	}
+	return count
 }
 
-// WriteMetrics writes all collected metrics in Prometheus format
-func (c *Collector) WriteMetrics(w io.Writer, metricsMap map[string]string) error {
-	for _, metricsText := range metricsMap {
-		if _, err := w.Write([]byte(metricsText)); err != nil {
-			return fmt.Errorf("failed to write metrics: %w", err)
+// addCustomLabels adds configured custom labels to all metric samples
+func (c *Collector) addCustomLabels(families []*openmetrics.Family) {
+	for _, family := range families {
+		for i := range family.Samples {
+			for _, name := range c.labelNames {
+				family.Samples[i].SetLabel(name, c.config.Labels[name])
+			}
 		}
 	}
-	return nil
 }
 
-// Health checks if the Slurm API is reachable
-func (c *Collector) Health(ctx context.Context) error {
-	url := c.config.Slurm.URL
+// UpstreamHealth is the result of a health check of one upstream URL
+type UpstreamHealth struct {
+	URL    string
+	Active bool
+	Err    error
+}
+
+// CheckUpstreams checks every configured Slurm URL, in configuration order
+func (c *Collector) CheckUpstreams(ctx context.Context) []UpstreamHealth {
+	active := c.upstreams.activeIndex()
+	results := make([]UpstreamHealth, len(c.config.Slurm.URLs))
+	for i, url := range c.config.Slurm.URLs {
+		results[i] = UpstreamHealth{URL: url, Active: i == active, Err: c.checkURL(ctx, url)}
+	}
+	return results
+}
 
+// checkURL checks if a single Slurm API URL is reachable and records the
+// outcome
+func (c *Collector) checkURL(ctx context.Context, url string) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create health check request: %w", err)
 	}
 
+	err = c.requestURL(req)
+	if ctx.Err() == nil {
+		c.status.upstream(url, err, time.Now())
+		if err == nil {
+			c.status.healthy(time.Now())
+		}
+	}
+	return err
+}
+
+// requestURL sends a health check request
+func (c *Collector) requestURL(req *http.Request) error {
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("slurm API is not reachable: %w", err)
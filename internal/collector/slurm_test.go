@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/metrics"
+)
+
+var (
+	testRegistryOnce sync.Once
+	testRegistry     *metrics.Registry
+)
+
+// newTestCollector creates a collector sharing a single metrics registry,
+// since the exporter metrics are registered globally
+func newTestCollector(t testing.TB, cfg *config.Config) *Collector {
+	t.Helper()
+
+	testRegistryOnce.Do(func() {
+		testRegistry = metrics.NewRegistry("test", "test", "test", false)
+	})
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Invalid test configuration: %v", err)
+	}
+
+	c, err := NewCollector(cfg, testRegistry, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("Failed to create collector: %v", err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// testConfig returns a minimal valid configuration pointing at url
+func testConfig(url string, endpoints ...config.EndpointConfig) *config.Config {
+	return &config.Config{
+		Slurm:     config.SlurmConfig{URL: url, Timeout: "5s"},
+		Server:    config.ServerConfig{Port: 8080},
+		Endpoints: endpoints,
+	}
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// staleSeries remembers a series seen in a previous snapshot
+type staleSeries struct {
+	family   *openmetrics.Family // metadata only, without samples
+	sample   openmetrics.Sample
+	lastSeen time.Time
+}
+
+// staleTracker keeps the series of the previous snapshots per endpoint so
+// that vanished series can be exported with an explicit zero value
+type staleTracker struct {
+	mu          sync.Mutex
+	gracePeriod time.Duration
+	series      map[string]map[string]*staleSeries
+}
+
+// newStaleTracker creates a tracker forgetting series after the grace period
+func newStaleTracker(gracePeriod time.Duration) *staleTracker {
+	return &staleTracker{
+		gracePeriod: gracePeriod,
+		series:      make(map[string]map[string]*staleSeries),
+	}
+}
+
+// apply records the series of the current snapshot and appends a zero sample
+// for every series that vanished less than the grace period ago. Only gauges
+// and untyped families are tracked, since a zero counter would read as a reset.
+func (t *staleTracker) apply(endpoint string, families []*openmetrics.Family, now time.Time) []*openmetrics.Family {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	known, ok := t.series[endpoint]
+	if !ok {
+		known = make(map[string]*staleSeries)
+		t.series[endpoint] = known
+	}
+
+	byName := make(map[string]*openmetrics.Family, len(families))
+	current := make(map[string]struct{})
+	for _, family := range families {
+		byName[family.Name] = family
+		if family.Type != openmetrics.TypeGauge && family.Type != openmetrics.TypeUntyped {
+			continue
+		}
+
+		metadata := &openmetrics.Family{Name: family.Name, Help: family.Help, Type: family.Type, Unit: family.Unit}
+		for _, sample := range family.Samples {
+			key := sample.Key()
+			current[key] = struct{}{}
+			known[key] = &staleSeries{family: metadata, sample: sample.Clone(), lastSeen: now}
+		}
+	}
+
+	// Walk the vanished series in a stable order to keep the output deterministic
+	var vanished []string
+	for key, series := range known {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		if now.Sub(series.lastSeen) > t.gracePeriod {
+			delete(known, key)
+			continue
+		}
+		vanished = append(vanished, key)
+	}
+	sort.Strings(vanished)
+
+	for _, key := range vanished {
+		series := known[key]
+
+		family, ok := byName[series.family.Name]
+		if !ok {
+			family = &openmetrics.Family{
+				Name: series.family.Name,
+				Help: series.family.Help,
+				Type: series.family.Type,
+				Unit: series.family.Unit,
+			}
+			byName[family.Name] = family
+			families = append(families, family)
+		}
+
+		sample := series.sample.Clone()
+		sample.Value = 0
+		sample.HasTimestamp = false
+		family.Samples = append(family.Samples, sample)
+	}
+
+	return families
+}
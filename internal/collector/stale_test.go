@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+func nodeFamily(nodes ...string) []*openmetrics.Family {
+	family := &openmetrics.Family{Name: "slurm_node_cpus", Type: openmetrics.TypeGauge}
+	for _, node := range nodes {
+		family.Samples = append(family.Samples, openmetrics.Sample{
+			Name:   "slurm_node_cpus",
+			Labels: []openmetrics.Label{{Name: "node", Value: node}},
+			Value:  2,
+		})
+	}
+	return []*openmetrics.Family{family}
+}
+
+func TestStaleTracker(t *testing.T) {
+	tracker := newStaleTracker(time.Minute)
+	start := time.Now()
+
+	tracker.apply("nodes", nodeFamily("c1", "c2"), start)
+
+	// c2 vanished: it must be exported with a zero value
+	families := tracker.apply("nodes", nodeFamily("c1"), start.Add(30*time.Second))
+	if len(families[0].Samples) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(families[0].Samples))
+	}
+	stale := families[0].Samples[1]
+	if node, _ := stale.Label("node"); node != "c2" || stale.Value != 0 {
+		t.Errorf("Expected zero sample for c2, got node=%s value=%v", node, stale.Value)
+	}
+
+	// After the grace period c2 is forgotten
+	families = tracker.apply("nodes", nodeFamily("c1"), start.Add(2*time.Minute))
+	if len(families[0].Samples) != 1 {
+		t.Errorf("Expected c2 to be forgotten, got %d samples", len(families[0].Samples))
+	}
+
+	// A whole family vanishing is recreated from the remembered metadata
+	families = tracker.apply("nodes", nil, start.Add(2*time.Minute+time.Second))
+	if len(families) != 1 || families[0].Name != "slurm_node_cpus" || families[0].Type != openmetrics.TypeGauge {
+		t.Fatalf("Expected the slurm_node_cpus family to be recreated, got %+v", families)
+	}
+}
+
+func TestStaleTrackerSkipsCounters(t *testing.T) {
+	tracker := newStaleTracker(time.Minute)
+	now := time.Now()
+
+	counter := []*openmetrics.Family{{
+		Name:    "slurm_backfilled_jobs_total",
+		Type:    openmetrics.TypeCounter,
+		Samples: []openmetrics.Sample{{Name: "slurm_backfilled_jobs_total", Value: 4}},
+	}}
+	tracker.apply("scheduler", counter, now)
+
+	if families := tracker.apply("scheduler", nil, now.Add(time.Second)); len(families) != 0 {
+		t.Errorf("Expected counters not to be tracked, got %d families", len(families))
+	}
+}
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// EndpointStatus describes the outcome of the latest scrapes of an endpoint
+type EndpointStatus struct {
+	Name         string
+	LastAttempt  time.Time
+	LastSuccess  time.Time
+	LastDuration time.Duration
+	LastError    string
+	Stale        bool
+}
+
+// UpstreamStatus describes the outcome of the latest request to a Slurm URL,
+// by a scrape or a health check
+type UpstreamStatus struct {
+	URL       string
+	Active    bool
+	LastCheck time.Time
+	LastError string
+}
+
+// statusTracker records scrape and health check outcomes for readiness
+type statusTracker struct {
+	mu          sync.Mutex
+	endpoints   map[string]*EndpointStatus
+	upstreams   map[string]*UpstreamStatus
+	lastHealthy time.Time
+}
+
+// newStatusTracker creates an empty status tracker
+func newStatusTracker() *statusTracker {
+	return &statusTracker{
+		endpoints: make(map[string]*EndpointStatus),
+		upstreams: make(map[string]*UpstreamStatus),
+	}
+}
+
+// record stores the outcome of a scrape of an endpoint
+func (t *statusTracker) record(endpoint string, err error, stale bool, duration time.Duration, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status, ok := t.endpoints[endpoint]
+	if !ok {
+		status = &EndpointStatus{Name: endpoint}
+		t.endpoints[endpoint] = status
+	}
+	status.LastAttempt = now
+	status.LastDuration = duration
+	status.Stale = stale
+	if err != nil {
+		status.LastError = err.Error()
+		return
+	}
+	status.LastSuccess = now
+	status.LastError = ""
+}
+
+// upstream stores the outcome of a request to a Slurm URL
+func (t *statusTracker) upstream(url string, err error, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status, ok := t.upstreams[url]
+	if !ok {
+		status = &UpstreamStatus{URL: url}
+		t.upstreams[url] = status
+	}
+	status.LastCheck = now
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+}
+
+// healthy records a successful health check
+func (t *statusTracker) healthy(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastHealthy = now
+}
+
+// EndpointStatuses returns the status of every enabled endpoint, in
+// configuration order. Endpoints never scraped have a zero LastAttempt.
+func (c *Collector) EndpointStatuses() []EndpointStatus {
+	c.status.mu.Lock()
+	defer c.status.mu.Unlock()
+
+	endpoints := c.config.GetEnabledEndpoints()
+	statuses := make([]EndpointStatus, len(endpoints))
+	for i, endpoint := range endpoints {
+		if status, ok := c.status.endpoints[endpoint.Name]; ok {
+			statuses[i] = *status
+		} else {
+			statuses[i] = EndpointStatus{Name: endpoint.Name}
+		}
+	}
+	return statuses
+}
+
+// UpstreamStatuses returns the status of every Slurm URL, in configuration
+// order, as recorded by the latest scrapes and health checks. URLs never
+// requested have a zero LastCheck.
+func (c *Collector) UpstreamStatuses() []UpstreamStatus {
+	active := c.upstreams.activeIndex()
+
+	c.status.mu.Lock()
+	defer c.status.mu.Unlock()
+
+	statuses := make([]UpstreamStatus, len(c.config.Slurm.URLs))
+	for i, url := range c.config.Slurm.URLs {
+		if status, ok := c.status.upstreams[url]; ok {
+			statuses[i] = *status
+		} else {
+			statuses[i] = UpstreamStatus{URL: url}
+		}
+		statuses[i].Active = i == active
+	}
+	return statuses
+}
+
+// LastSuccess returns the time of the latest successful scrape of any
+// endpoint or health check of slurmctld
+func (c *Collector) LastSuccess() time.Time {
+	c.status.mu.Lock()
+	defer c.status.mu.Unlock()
+
+	last := c.status.lastHealthy
+	for _, status := range c.status.endpoints {
+		if status.LastSuccess.After(last) {
+			last = status.LastSuccess
+		}
+	}
+	return last
+}
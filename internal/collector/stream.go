@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// limitedBody bounds the bytes read from an upstream response and remembers
+// whether the limit was hit, since the parser may report a truncated line
+// before the read error
+type limitedBody struct {
+	reader   io.Reader
+	limit    int64
+	exceeded bool
+}
+
+// newLimitedBody wraps an upstream response body
+func newLimitedBody(body io.Reader, limit int64) *limitedBody {
+	return &limitedBody{reader: http.MaxBytesReader(nil, io.NopCloser(body), limit), limit: limit}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// streamable reports whether an endpoint can be transformed one family at a
+// time. Stale series, cardinality limits, node group aggregates and
+// last-known-good serving need the whole payload.
+func (c *Collector) streamable(endpoint config.EndpointConfig) bool {
+	return c.stale == nil &&
+		c.nodeGroups == nil &&
+		c.lastGood == nil &&
+		endpoint.MaxSeries == 0 &&
+		len(endpoint.MaxValues) == 0
+}
+
+// streamEndpoint decodes the upstream response one family at a time, applies
+// the per-family transformations and writes each family to w as soon as it
+// is complete, so memory stays bounded by the largest family. A failure in
+// the middle of the body leaves the families already written in place.
+func (c *Collector) streamEndpoint(ctx context.Context, endpoint config.EndpointConfig, w io.Writer) error {
+	bw, ok := w.(*bufio.Writer)
+	if !ok {
+		bw = bufio.NewWriter(w)
+		defer bw.Flush()
+	}
+
+	series := 0
+	err := c.fetchWithRetry(ctx, endpoint, func(body io.Reader) error {
+		decoder := openmetrics.NewDecoder(body)
+		batch := make([]*openmetrics.Family, 1)
+		for {
+			family, err := decoder.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to parse response: %w", err)
+			}
+
+			batch[0] = family
+			if c.nodeFilter != nil {
+				c.nodeFilter.apply(batch)
+			}
+			c.correctTypes(batch)
+			if c.enricher != nil {
+				c.enricher.Enrich(batch)
+			}
+			if c.topology != nil {
+				c.topology.Enrich(batch)
+			}
+			c.addCustomLabels(batch)
+			c.summary.observe(batch, time.Now())
+
+			series += len(family.Samples)
+			if err := openmetrics.WriteFamily(bw, family); err != nil {
+				return fmt.Errorf("failed to encode metrics: %w", err)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	c.registry.SeriesCount.WithLabelValues(endpoint.Name).Set(float64(series))
+	return nil
+}
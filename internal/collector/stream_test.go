@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+// fixtureServer serves every test_data fixture under /metrics/<name>, e.g.
+// metrics_jobs_users_accts.txt as /metrics/jobs-users-accts
+func fixtureServer(t testing.TB) (*httptest.Server, []config.EndpointConfig) {
+	t.Helper()
+
+	files, err := filepath.Glob("../../test_data/metrics_*.txt")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No test data found: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	var endpoints []config.EndpointConfig
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		name := strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "metrics_"), ".txt"), "_", "-")
+		path := "/metrics/" + name
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Write(data)
+		})
+		endpoints = append(endpoints, config.EndpointConfig{Name: name, Path: path, Enabled: true})
+	}
+
+	return httptest.NewServer(mux), endpoints
+}
+
+func TestStreamingMatchesBuffered(t *testing.T) {
+	server, endpoints := fixtureServer(t)
+	defer server.Close()
+
+	cfg := testConfig(server.URL, endpoints...)
+	cfg.Labels = map[string]string{"cluster": "c1", "env": "test"}
+	cfg.TypeCorrection.Enabled = true
+	c := newTestCollector(t, cfg)
+
+	for _, endpoint := range endpoints {
+		if !c.streamable(endpoint) {
+			t.Fatalf("Expected endpoint %s to be streamable", endpoint.Name)
+		}
+
+		buffered, err := c.collectEndpoint(context.Background(), endpoint)
+		if err != nil {
+			t.Fatalf("Failed to collect %s: %v", endpoint.Name, err)
+		}
+
+		var streamed strings.Builder
+		if err := c.writeEndpoint(context.Background(), endpoint, &streamed, true); err != nil {
+			t.Fatalf("Failed to stream %s: %v", endpoint.Name, err)
+		}
+
+		if streamed.String() != buffered {
+			t.Errorf("Streamed output of %s differs from the buffered output", endpoint.Name)
+		}
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("# TYPE slurm_jobs gauge\nslurm_jobs 1\n"))
+	}))
+	defer server.Close()
+
+	cfg := testConfig(server.URL, config.EndpointConfig{Name: "jobs", Path: "/metrics/jobs", Enabled: true})
+	cfg.Slurm.MaxResponseBytes = 16
+	c := newTestCollector(t, cfg)
+
+	for _, stream := range []bool{false, true} {
+		err := c.writeEndpoint(context.Background(), cfg.Endpoints[0], io.Discard, stream)
+		if err == nil || !strings.Contains(err.Error(), "maximum size of 16 bytes") {
+			t.Errorf("Expected the response size limit to be enforced (streaming %v), got %v", stream, err)
+		}
+	}
+}
+
+// BenchmarkCollectBuffered measures collecting every fixture into per-endpoint
+// strings before writing them, as CollectAll does
+func BenchmarkCollectBuffered(b *testing.B) {
+	server, endpoints := fixtureServer(b)
+	defer server.Close()
+	cfg := testConfig(server.URL, endpoints...)
+	cfg.Labels = map[string]string{"cluster": "c1"}
+	c := newTestCollector(b, cfg)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results, _ := c.CollectAll(context.Background())
+		for _, text := range results {
+			io.WriteString(io.Discard, text)
+		}
+	}
+}
+
+// BenchmarkWriteAllStreaming measures streaming every fixture to the writer
+func BenchmarkWriteAllStreaming(b *testing.B) {
+	server, endpoints := fixtureServer(b)
+	defer server.Close()
+	cfg := testConfig(server.URL, endpoints...)
+	cfg.Labels = map[string]string{"cluster": "c1"}
+	c := newTestCollector(b, cfg)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.WriteAll(context.Background(), io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// Family name prefixes of the cluster-wide node state and job state counts
+const (
+	nodeStatePrefix = "slurm_nodes_"
+	jobStatePrefix  = "slurm_jobs_"
+)
+
+// StateCount is the number of nodes or jobs in a state
+type StateCount struct {
+	State string
+	Count float64
+}
+
+// ClusterSummary holds the node and job counts seen in the latest scrapes
+type ClusterSummary struct {
+	Nodes      float64
+	NodeStates []StateCount
+	Jobs       float64
+	JobStates  []StateCount
+	UpdatedAt  time.Time
+}
+
+// summaryTracker keeps the cluster-wide counts from the scraped families
+type summaryTracker struct {
+	mu         sync.Mutex
+	nodes      float64
+	jobs       float64
+	nodeStates map[string]float64
+	jobStates  map[string]float64
+	updatedAt  time.Time
+}
+
+// newSummaryTracker creates an empty summary tracker
+func newSummaryTracker() *summaryTracker {
+	return &summaryTracker{nodeStates: make(map[string]float64), jobStates: make(map[string]float64)}
+}
+
+// observe records the counts found in the families. Allocation gauges such
+// as slurm_jobs_cpus_alloc share the prefix of the states and are skipped.
+func (t *summaryTracker) observe(families []*openmetrics.Family, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, family := range families {
+		if len(family.Samples) == 0 {
+			continue
+		}
+		switch {
+		case family.Name == "slurm_nodes":
+			t.nodes = sum(family)
+		case family.Name == "slurm_jobs":
+			t.jobs = sum(family)
+		case strings.HasPrefix(family.Name, nodeStatePrefix):
+			t.nodeStates[strings.TrimPrefix(family.Name, nodeStatePrefix)] = sum(family)
+		case strings.HasPrefix(family.Name, jobStatePrefix) && !strings.HasSuffix(family.Name, "_alloc"):
+			t.jobStates[strings.TrimPrefix(family.Name, jobStatePrefix)] = sum(family)
+		default:
+			continue
+		}
+		t.updatedAt = now
+	}
+}
+
+// sum adds the values of every sample of a family
+func sum(family *openmetrics.Family) float64 {
+	total := 0.0
+	for _, sample := range family.Samples {
+		total += sample.Value
+	}
+	return total
+}
+
+// sortedCounts returns the non-zero counts sorted by decreasing count, then state
+func sortedCounts(states map[string]float64) []StateCount {
+	var counts []StateCount
+	for state, count := range states {
+		if count != 0 {
+			counts = append(counts, StateCount{State: state, Count: count})
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].State < counts[j].State
+	})
+	return counts
+}
+
+// Summary returns the node and job counts from the latest scrapes. States
+// with a zero count are left out; UpdatedAt is zero until counts were seen.
+func (c *Collector) Summary() ClusterSummary {
+	c.summary.mu.Lock()
+	defer c.summary.mu.Unlock()
+
+	return ClusterSummary{
+		Nodes:      c.summary.nodes,
+		NodeStates: sortedCounts(c.summary.nodeStates),
+		Jobs:       c.summary.jobs,
+		JobStates:  sortedCounts(c.summary.jobStates),
+		UpdatedAt:  c.summary.updatedAt,
+	}
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	server, endpoints := fixtureServer(t)
+	defer server.Close()
+
+	for _, streaming := range []bool{true, false} {
+		cfg := testConfig(server.URL, endpoints...)
+		if !streaming {
+			cfg.StaleSeries.Enabled = true
+		}
+		c := newTestCollector(t, cfg)
+
+		if !c.Summary().UpdatedAt.IsZero() {
+			t.Fatalf("Expected an empty summary before the first scrape")
+		}
+		if err := c.WriteAll(context.Background(), io.Discard); err != nil {
+			t.Fatalf("Scrape failed: %v", err)
+		}
+
+		summary := c.Summary()
+		if summary.UpdatedAt.IsZero() || summary.Nodes != 2 || summary.Jobs != 2 {
+			t.Errorf("streaming=%v: unexpected totals %+v", streaming, summary)
+		}
+		if len(summary.NodeStates) != 1 || summary.NodeStates[0] != (StateCount{State: "idle", Count: 2}) {
+			t.Errorf("streaming=%v: unexpected node states %+v", streaming, summary.NodeStates)
+		}
+		for _, state := range summary.JobStates {
+			if state.State == "cpus_alloc" || state.State == "memory_alloc" || state.State == "nodes_alloc" {
+				t.Errorf("streaming=%v: allocation gauge %s counted as a job state", streaming, state.State)
+			}
+		}
+		if len(summary.JobStates) == 0 || summary.JobStates[0].Count != 2 {
+			t.Errorf("streaming=%v: unexpected job states %+v", streaming, summary.JobStates)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// builtinCounters lists the upstream metrics that Slurm declares as gauges
+// although they only ever increase (until the statistics are reset)
+var builtinCounters = []string{
+	"slurm_backfilled_jobs",
+	"slurm_backfilled_het_jobs",
+	"slurm_bf_cycle_cnt",
+	"slurm_bf_cycle_tot",
+	"slurm_bf_depth_tot",
+	"slurm_bf_depth_try_tot",
+	"slurm_bf_queue_len_tot",
+	"slurm_bf_table_size_tot",
+	"slurm_bf_exit_end",
+	"slurm_bf_exit_max_job_start",
+	"slurm_bf_exit_max_job_test",
+	"slurm_bf_exit_state_changed",
+	"slurm_bf_exit_table_limit",
+	"slurm_bf_exit_timeout",
+	"slurm_schedule_cycle_cnt",
+	"slurm_schedule_cycle_depth",
+	"slurm_schedule_cycle_tot",
+	"slurm_sched_exit_end",
+	"slurm_sched_exit_max_depth",
+	"slurm_sched_exit_max_job_start",
+	"slurm_sched_exit_lic",
+	"slurm_sched_exit_rpc_cnt",
+	"slurm_sched_exit_timeout",
+	"slurm_sdiag_jobs_canceled",
+	"slurm_sdiag_jobs_completed",
+	"slurm_sdiag_jobs_failed",
+	"slurm_sdiag_jobs_started",
+	"slurm_sdiag_jobs_submitted",
+}
+
+// buildTypeCorrections merges the built-in table with the configured overrides
+func buildTypeCorrections(overrides map[string]string) map[string]string {
+	corrections := make(map[string]string, len(builtinCounters)+len(overrides))
+	for _, name := range builtinCounters {
+		corrections[name] = openmetrics.TypeCounter
+	}
+	for name, metricType := range overrides {
+		corrections[name] = metricType
+	}
+	return corrections
+}
+
+// correctTypes rewrites the declared type of the families listed in the
+// correction table. Families converted to counters get the _total suffix and
+// every rewrite is recorded in the HELP text.
+func (c *Collector) correctTypes(families []*openmetrics.Family) {
+	if !c.config.TypeCorrection.Enabled {
+		return
+	}
+
+	for _, family := range families {
+		metricType, ok := c.typeCorrections[family.Name]
+		if !ok || metricType == family.Type {
+			continue
+		}
+
+		note := fmt.Sprintf("type corrected by exporter from %s to %s", family.Type, metricType)
+		if metricType == openmetrics.TypeCounter && !strings.HasSuffix(family.Name, "_total") {
+			note += fmt.Sprintf(", renamed from %s", family.Name)
+			family.Rename(family.Name + "_total")
+		}
+
+		if family.Help != "" {
+			family.Help = fmt.Sprintf("%s (%s)", family.Help, note)
+		} else {
+			family.Help = fmt.Sprintf("(%s)", note)
+		}
+		family.Type = metricType
+	}
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+func TestCorrectTypes(t *testing.T) {
+	cfg := &config.Config{
+		TypeCorrection: config.TypeCorrectionConfig{
+			Enabled: true,
+			Overrides: map[string]string{
+				"slurm_bf_cycle_cnt": "gauge",
+				"slurm_agent_cnt":    "counter",
+			},
+		},
+	}
+	c := &Collector{config: cfg, typeCorrections: buildTypeCorrections(cfg.TypeCorrection.Overrides)}
+
+	families, err := openmetrics.Parse(strings.NewReader(`# HELP slurm_backfilled_jobs Total backfilled jobs since reset
+# TYPE slurm_backfilled_jobs gauge
+slurm_backfilled_jobs 1
+# HELP slurm_bf_cycle_cnt Backfill cycle count
+# TYPE slurm_bf_cycle_cnt gauge
+slurm_bf_cycle_cnt 4
+# HELP slurm_agent_cnt Number of agent threads
+# TYPE slurm_agent_cnt gauge
+slurm_agent_cnt 0
+# HELP slurm_bf_queue_len Backfill queue length
+# TYPE slurm_bf_queue_len gauge
+slurm_bf_queue_len 2
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	c.correctTypes(families)
+
+	expected := []struct {
+		name       string
+		metricType string
+		noted      bool
+	}{
+		{"slurm_backfilled_jobs_total", "counter", true},
+		{"slurm_bf_cycle_cnt", "gauge", false},
+		{"slurm_agent_cnt_total", "counter", true},
+		{"slurm_bf_queue_len", "gauge", false},
+	}
+
+	for i, want := range expected {
+		family := families[i]
+		if family.Name != want.name || family.Samples[0].Name != want.name {
+			t.Errorf("Expected family %s, got %s (sample %s)", want.name, family.Name, family.Samples[0].Name)
+		}
+		if family.Type != want.metricType {
+			t.Errorf("Expected %s to be a %s, got %s", want.name, want.metricType, family.Type)
+		}
+		if noted := strings.Contains(family.Help, "type corrected by exporter"); noted != want.noted {
+			t.Errorf("Unexpected help text for %s: %q", want.name, family.Help)
+		}
+	}
+}
+
+func TestCorrectTypesDisabled(t *testing.T) {
+	cfg := &config.Config{}
+	c := &Collector{config: cfg, typeCorrections: buildTypeCorrections(nil)}
+
+	families := []*openmetrics.Family{{
+		Name:    "slurm_backfilled_jobs",
+		Type:    "gauge",
+		Samples: []openmetrics.Sample{{Name: "slurm_backfilled_jobs", Value: 1}},
+	}}
+
+	c.correctTypes(families)
+
+	if families[0].Name != "slurm_backfilled_jobs" || families[0].Type != "gauge" {
+		t.Errorf("Expected family to be left untouched, got %s (%s)", families[0].Name, families[0].Type)
+	}
+}
@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// upstreamPool tracks which of the configured slurmctld URLs is in use. The
+// first URL is the primary: after a failover the primary is tried again once
+// per recheck interval and becomes active again as soon as it answers.
+type upstreamPool struct {
+	urls            []string
+	recheckInterval time.Duration
+
+	mu          sync.Mutex
+	active      int
+	lastRecheck time.Time
+}
+
+// newUpstreamPool creates a pool starting on the primary URL
+func newUpstreamPool(urls []string, recheckInterval time.Duration) *upstreamPool {
+	return &upstreamPool{urls: urls, recheckInterval: recheckInterval}
+}
+
+// order returns the URL indexes in the order they should be tried: the
+// primary first when it is due for a recheck, then the active URL, then the
+// remaining URLs in configuration order
+func (p *upstreamPool) order(now time.Time) []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	order := make([]int, 0, len(p.urls))
+	if p.active != 0 && now.Sub(p.lastRecheck) >= p.recheckInterval {
+		p.lastRecheck = now
+		order = append(order, 0)
+	}
+	if len(order) == 0 || order[0] != p.active {
+		order = append(order, p.active)
+	}
+	for i := range p.urls {
+		if i != p.active && (len(order) == 0 || order[0] != i) {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// markActive records the URL that answered successfully and reports whether
+// the active URL changed
+func (p *upstreamPool) markActive(index int, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.active == index {
+		return false
+	}
+	if index != 0 {
+		// Leaving the primary: wait a full interval before probing it again
+		p.lastRecheck = now
+	}
+	p.active = index
+	return true
+}
+
+// activeIndex returns the index of the URL currently in use
+func (p *upstreamPool) activeIndex() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.active
+}
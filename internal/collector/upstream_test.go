@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+func TestUpstreamFailover(t *testing.T) {
+	var primaryDown atomic.Bool
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if primaryDown.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("# TYPE slurm_jobs gauge\nslurm_jobs 1\n"))
+	}))
+	defer primary.Close()
+
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("# TYPE slurm_jobs gauge\nslurm_jobs 2\n"))
+	}))
+	defer backup.Close()
+
+	cfg := testConfig("", config.EndpointConfig{Name: "jobs", Path: "/metrics/jobs", Enabled: true})
+	cfg.Slurm.URLs = []string{primary.URL, backup.URL}
+	cfg.Slurm.PrimaryRecheckInterval = "1h"
+	c := newTestCollector(t, cfg)
+
+	collect := func() string {
+		t.Helper()
+		text, err := c.collectEndpoint(context.Background(), cfg.Endpoints[0])
+		if err != nil {
+			t.Fatalf("Failed to collect: %v", err)
+		}
+		return text
+	}
+
+	if text := collect(); text != "# TYPE slurm_jobs gauge\nslurm_jobs 1\n" {
+		t.Errorf("Expected the primary to answer, got %q", text)
+	}
+
+	primaryDown.Store(true)
+	if text := collect(); text != "# TYPE slurm_jobs gauge\nslurm_jobs 2\n" {
+		t.Errorf("Expected failover to the backup, got %q", text)
+	}
+	statuses := c.UpstreamStatuses()
+	if statuses[0].LastError != "unexpected status code: 503" || statuses[1].LastError != "" || statuses[1].LastCheck.IsZero() || !statuses[1].Active {
+		t.Errorf("Expected the scrapes to record the primary as unreachable, got %+v", statuses)
+	}
+	if c.upstreams.activeIndex() != 1 {
+		t.Errorf("Expected the backup to be active")
+	}
+
+	// The primary recovered but is not rechecked before the interval elapses
+	primaryDown.Store(false)
+	if text := collect(); text != "# TYPE slurm_jobs gauge\nslurm_jobs 2\n" {
+		t.Errorf("Expected the backup to stay active, got %q", text)
+	}
+
+	// Once due, the primary is preferred again
+	c.upstreams.lastRecheck = time.Now().Add(-2 * time.Hour)
+	if text := collect(); text != "# TYPE slurm_jobs gauge\nslurm_jobs 1\n" {
+		t.Errorf("Expected to fail back to the primary, got %q", text)
+	}
+
+	health := c.CheckUpstreams(context.Background())
+	if len(health) != 2 || !health[0].Active || health[0].Err != nil || health[1].Err != nil {
+		t.Errorf("Unexpected upstream health %+v", health)
+	}
+}
+
+func TestUpstreamPoolOrder(t *testing.T) {
+	pool := newUpstreamPool([]string{"a", "b", "c"}, time.Minute)
+	now := time.Now()
+
+	if order := pool.order(now); !equalInts(order, []int{0, 1, 2}) {
+		t.Errorf("Expected primary first, got %v", order)
+	}
+
+	pool.markActive(2, now)
+	if order := pool.order(now.Add(time.Second)); !equalInts(order, []int{2, 0, 1}) {
+		t.Errorf("Expected active backup first, got %v", order)
+	}
+	if order := pool.order(now.Add(2 * time.Minute)); !equalInts(order, []int{0, 2, 1}) {
+		t.Errorf("Expected primary recheck first, got %v", order)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -2,33 +2,142 @@ package config
 
 import (
 	"fmt"
+	"math"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/hostlist"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the main configuration structure
 type Config struct {
-	Slurm     SlurmConfig       `yaml:"slurm"`
-	Server    ServerConfig      `yaml:"server"`
-	Endpoints []EndpointConfig  `yaml:"endpoints"`
-	Labels    map[string]string `yaml:"labels"`
-	Logging   LoggingConfig     `yaml:"logging"`
+	Slurm          SlurmConfig          `yaml:"slurm"`
+	Server         ServerConfig         `yaml:"server"`
+	Endpoints      []EndpointConfig     `yaml:"endpoints"`
+	Labels         map[string]string    `yaml:"labels"`
+	Logging        LoggingConfig        `yaml:"logging"`
+	TypeCorrection TypeCorrectionConfig `yaml:"type_correction"`
+	StaleSeries    StaleSeriesConfig    `yaml:"stale_series"`
+	Enrichment     EnrichmentConfig     `yaml:"enrichment"`
+	Topology       TopologyConfig       `yaml:"topology"`
+	NodeFilter     NodeFilterConfig     `yaml:"node_filter"`
+	NodeGroups     NodeGroupsConfig     `yaml:"node_groups"`
+	LastKnownGood  LastKnownGoodConfig  `yaml:"last_known_good"`
+	RemoteWrite    RemoteWriteConfig    `yaml:"remote_write"`
+	OTLP           OTLPConfig           `yaml:"otlp"`
+	Formats        FormatsConfig        `yaml:"formats"`
 }
 
+// DefaultMaxResponseBytes is the default limit of an upstream response body
+const DefaultMaxResponseBytes = 64 << 20
+
 // SlurmConfig holds the Slurm API connection settings
 type SlurmConfig struct {
-	URL               string `yaml:"url"`
-	Timeout           string `yaml:"timeout"`
-	TLSInsecureVerify bool   `yaml:"tls_insecure_skip_verify"`
+	URL                    string               `yaml:"url"`
+	URLs                   []string             `yaml:"urls"`
+	PrimaryRecheckInterval string               `yaml:"primary_recheck_interval"`
+	Timeout                string               `yaml:"timeout"`
+	TLSInsecureVerify      bool                 `yaml:"tls_insecure_skip_verify"`
+	MaxResponseBytes       int64                `yaml:"max_response_bytes"`
+	Compression            []string             `yaml:"compression"`
+	Retry                  RetryConfig          `yaml:"retry"`
+	CircuitBreaker         CircuitBreakerConfig `yaml:"circuit_breaker"`
+}
+
+// RetryConfig holds the retry settings for upstream requests. Retries use a
+// jittered exponential backoff and never exceed the scrape deadline.
+type RetryConfig struct {
+	MaxAttempts    int    `yaml:"max_attempts"`
+	InitialBackoff string `yaml:"initial_backoff"`
+	MaxBackoff     string `yaml:"max_backoff"`
+}
+
+// CircuitBreakerConfig holds the per-endpoint circuit breaker settings. After
+// FailureThreshold consecutive failures the endpoint is not queried for
+// OpenDuration, after which a single trial request is let through.
+type CircuitBreakerConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	FailureThreshold int    `yaml:"failure_threshold"`
+	OpenDuration     string `yaml:"open_duration"`
 }
 
 // ServerConfig holds the HTTP server configuration
 type ServerConfig struct {
-	Port      int             `yaml:"port"`
-	BasicAuth BasicAuthConfig `yaml:"basic_auth"`
-	SSL       SSLConfig       `yaml:"ssl"`
+	Port            int             `yaml:"port"`
+	BasicAuth       BasicAuthConfig `yaml:"basic_auth"`
+	SSL             SSLConfig       `yaml:"ssl"`
+	ReadyMaxAge     string          `yaml:"ready_max_age"`
+	CoalesceScrapes bool            `yaml:"coalesce_scrapes"`
+	Debug           DebugConfig     `yaml:"debug"`
+	AccessLog       AccessLogConfig `yaml:"access_log"`
+	RateLimit       RateLimitConfig `yaml:"rate_limit"`
+
+	// MaxConcurrentScrapes bounds the requests fanning out to slurmctld
+	// served at the same time. Zero means unlimited.
+	MaxConcurrentScrapes int `yaml:"max_concurrent_scrapes"`
+}
+
+// RateLimitConfig holds the per-client rate limit of the HTTP server: a token
+// bucket refilled at RequestsPerSecond and holding up to Burst requests. Zero
+// disables it. Clients are resolved with the trusted proxies of the access log.
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+// AccessLogConfig holds the structured access log written for every HTTP
+// request, separately from the application log. The X-Forwarded-For header is
+// only trusted when the request comes from one of the TrustedProxies, given
+// as IP addresses or CIDR ranges; the rate limit resolves clients the same way.
+type AccessLogConfig struct {
+	LogSinkConfig `yaml:",inline"`
+
+	Enabled        bool     `yaml:"enabled"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// LogSinkConfig selects where a log stream is written: stdout, stderr, a
+// rotated file, the local syslog daemon or the systemd journal
+type LogSinkConfig struct {
+	Output   string         `yaml:"output"`
+	File     LogFileConfig  `yaml:"file"`
+	Syslog   SyslogConfig   `yaml:"syslog"`
+	Journald JournaldConfig `yaml:"journald"`
+}
+
+// LogFileConfig holds the settings of a log file. The file is rotated once
+// it reaches MaxSizeMB, keeping MaxBackups previous files.
+type LogFileConfig struct {
+	Path       string `yaml:"path"`
+	MaxSizeMB  int    `yaml:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups"`
+}
+
+// SyslogConfig holds the settings of RFC 5424 messages sent to the local
+// syslog socket
+type SyslogConfig struct {
+	Socket   string `yaml:"socket"`
+	Facility string `yaml:"facility"`
+	Tag      string `yaml:"tag"`
+}
+
+// JournaldConfig holds the settings of messages sent to the systemd journal
+// over its native protocol
+type JournaldConfig struct {
+	Socket     string `yaml:"socket"`
+	Identifier string `yaml:"identifier"`
+}
+
+// DebugConfig holds the troubleshooting routes of the HTTP server. The
+// endpoint debug pages show raw upstream payloads and require basic auth.
+type DebugConfig struct {
+	Endpoints bool `yaml:"endpoints"`
+	Pprof     bool `yaml:"pprof"`
 }
 
 // BasicAuthConfig holds the Basic Authentication settings
@@ -50,12 +159,189 @@ type EndpointConfig struct {
 	Name    string `yaml:"name"`
 	Path    string `yaml:"path"`
 	Enabled bool   `yaml:"enabled"`
+
+	// Cardinality limits; series beyond them are aggregated into the
+	// "__other__" label value. Zero means unlimited.
+	MaxSeries int            `yaml:"max_series"`
+	MaxValues map[string]int `yaml:"max_values"`
 }
 
-// LoggingConfig holds the logging configuration
+// LoggingConfig holds the logging configuration. Format is text or json;
+// the output is one of the log sinks. For compatibility, output "json" means
+// JSON records on stdout.
 type LoggingConfig struct {
+	LogSinkConfig `yaml:",inline"`
+
 	Level  string `yaml:"level"`
-	Output string `yaml:"output"`
+	Format string `yaml:"format"`
+}
+
+// TypeCorrectionConfig holds the settings for correcting upstream metric types.
+// Overrides map a metric name to "counter" or "gauge" and take precedence over
+// the built-in table.
+type TypeCorrectionConfig struct {
+	Enabled   bool              `yaml:"enabled"`
+	Overrides map[string]string `yaml:"overrides"`
+}
+
+// StaleSeriesConfig holds the settings for remembering series that vanished
+// from the upstream response. Such series are exported with an explicit zero
+// value until the grace period expires.
+type StaleSeriesConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	GracePeriod string `yaml:"grace_period"`
+}
+
+// EnrichmentConfig holds the settings for joining username and account label
+// values against a mapping file and an optional LDAP directory
+type EnrichmentConfig struct {
+	Enabled bool       `yaml:"enabled"`
+	File    string     `yaml:"file"`
+	LDAP    LDAPConfig `yaml:"ldap"`
+}
+
+// LDAPConfig holds the LDAP lookup backend settings. Attributes map the
+// enrichment label (department, project, pi) to an LDAP attribute.
+// UsernameAttribute holds the username in the entries matched by UserFilter,
+// so that the results of a batched search can be matched to the users.
+type LDAPConfig struct {
+	Enabled           bool              `yaml:"enabled"`
+	URL               string            `yaml:"url"`
+	BindDN            string            `yaml:"bind_dn"`
+	BindPassword      string            `yaml:"bind_password"`
+	BaseDN            string            `yaml:"base_dn"`
+	UserFilter        string            `yaml:"user_filter"`
+	UsernameAttribute string            `yaml:"username_attribute"`
+	Attributes        map[string]string `yaml:"attributes"`
+	CacheTTL          string            `yaml:"cache_ttl"`
+	Timeout           string            `yaml:"timeout"`
+}
+
+// TopologyConfig holds the settings for adding switch, rack and chassis labels
+// to node series from a Slurm topology.conf and/or a mapping file
+type TopologyConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	TopologyFile string `yaml:"topology_file"`
+	MappingFile  string `yaml:"mapping_file"`
+}
+
+// NodeFilterConfig restricts the node series exported. Entries are Slurm
+// hostlist expressions such as "c[001-128],gpu[01-08]". When allow is empty
+// every node not denied is exported.
+type NodeFilterConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// NodeGroupConfig defines a named set of nodes. Members is either a Slurm
+// hostlist expression or a regular expression enclosed in slashes.
+type NodeGroupConfig struct {
+	Name    string
+	Members string
+}
+
+// IsRegex reports whether the members are given as a regular expression
+func (g NodeGroupConfig) IsRegex() bool {
+	return len(g.Members) >= 2 && strings.HasPrefix(g.Members, "/") && strings.HasSuffix(g.Members, "/")
+}
+
+// Pattern returns the regular expression without its enclosing slashes
+func (g NodeGroupConfig) Pattern() string {
+	return g.Members[1 : len(g.Members)-1]
+}
+
+// AnchoredPattern returns the regular expression anchored to match whole
+// node names, like PromQL regex matchers
+func (g NodeGroupConfig) AnchoredPattern() string {
+	return "^(?:" + g.Pattern() + ")$"
+}
+
+// NodeGroupsConfig holds the node groups in the order they are declared,
+// which decides the group of a node matching several definitions
+type NodeGroupsConfig []NodeGroupConfig
+
+// UnmarshalYAML decodes a mapping of group names to members, keeping the order
+func (n *NodeGroupsConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("node_groups must be a mapping of group names to hostlists or regexes")
+	}
+
+	groups := make(NodeGroupsConfig, 0, len(value.Content)/2)
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		var group NodeGroupConfig
+		if err := value.Content[i].Decode(&group.Name); err != nil {
+			return err
+		}
+		if err := value.Content[i+1].Decode(&group.Members); err != nil {
+			return fmt.Errorf("node_groups.%s: %w", group.Name, err)
+		}
+		groups = append(groups, group)
+	}
+
+	*n = groups
+	return nil
+}
+
+// LastKnownGoodConfig holds the settings for serving the last successful
+// payload of an endpoint when the upstream fails. IncludeTimestamps attaches
+// the original collection time to every sample so Prometheus knows the data is old.
+type LastKnownGoodConfig struct {
+	Enabled           bool   `yaml:"enabled"`
+	MaxAge            string `yaml:"max_age"`
+	IncludeTimestamps bool   `yaml:"include_timestamps"`
+}
+
+// RemoteWriteConfig holds the settings for pushing metrics to a Prometheus
+// remote-write receiver. Failed pushes are retried and at most QueueSize
+// pushes are kept in memory; the oldest is dropped when the queue is full.
+type RemoteWriteConfig struct {
+	Enabled   bool              `yaml:"enabled"`
+	URL       string            `yaml:"url"`
+	Interval  string            `yaml:"interval"`
+	Timeout   string            `yaml:"timeout"`
+	QueueSize int               `yaml:"queue_size"`
+	Headers   map[string]string `yaml:"headers"`
+	BasicAuth BasicAuthConfig   `yaml:"basic_auth"`
+	Retry     RetryConfig       `yaml:"retry"`
+}
+
+// OTLPConfig holds the settings for exporting metrics to an OpenTelemetry
+// collector over OTLP/HTTP or OTLP/gRPC. The configured labels are sent as
+// resource attributes rather than on every data point.
+type OTLPConfig struct {
+	Enabled  bool              `yaml:"enabled"`
+	Protocol string            `yaml:"protocol"`
+	Endpoint string            `yaml:"endpoint"`
+	Insecure bool              `yaml:"insecure"`
+	Interval string            `yaml:"interval"`
+	Timeout  string            `yaml:"timeout"`
+	Headers  map[string]string `yaml:"headers"`
+}
+
+// FormatsConfig holds the alternative output formats served next to the
+// Prometheus text format
+type FormatsConfig struct {
+	InfluxDB InfluxDBFormatConfig `yaml:"influxdb"`
+	Graphite GraphiteFormatConfig `yaml:"graphite"`
+}
+
+// InfluxDBFormatConfig holds the settings of the InfluxDB line protocol
+// output. Without a fixed measurement every metric is its own measurement with
+// a single "value" field; with one, the metric names become the field keys.
+type InfluxDBFormatConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Measurement string `yaml:"measurement"`
+	TrimPrefix  string `yaml:"trim_prefix"`
+}
+
+// GraphiteFormatConfig holds the settings of the Graphite plaintext output.
+// Labels become Graphite 1.1 tags when Tagged is set, otherwise their values
+// are appended to the metric path in label name order.
+type GraphiteFormatConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Prefix     string `yaml:"prefix"`
+	TrimPrefix string `yaml:"trim_prefix"`
+	Tagged     bool   `yaml:"tagged"`
 }
 
 // Load reads and parses the configuration file
@@ -80,11 +366,38 @@ func Load(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// endpointNamePattern restricts endpoint names to characters that are safe
+// in URL paths and HTTP route patterns
+var endpointNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
 	// Validate Slurm configuration
+	if c.Slurm.URL == "" && len(c.Slurm.URLs) == 0 {
+		return fmt.Errorf("slurm.url or slurm.urls is required")
+	}
+
+	// A single url is the primary; urls lists the primary first, then backups
+	if len(c.Slurm.URLs) == 0 {
+		c.Slurm.URLs = []string{c.Slurm.URL}
+	}
+	for i, url := range c.Slurm.URLs {
+		if url == "" {
+			return fmt.Errorf("slurm.urls[%d] must not be empty", i)
+		}
+	}
 	if c.Slurm.URL == "" {
-		return fmt.Errorf("slurm.url is required")
+		c.Slurm.URL = c.Slurm.URLs[0]
+	}
+	if c.Slurm.URL != c.Slurm.URLs[0] {
+		return fmt.Errorf("slurm.url must match the first entry of slurm.urls when both are set")
+	}
+
+	if c.Slurm.PrimaryRecheckInterval == "" {
+		c.Slurm.PrimaryRecheckInterval = "30s"
+	}
+	if _, err := time.ParseDuration(c.Slurm.PrimaryRecheckInterval); err != nil {
+		return fmt.Errorf("invalid slurm.primary_recheck_interval format: %w", err)
 	}
 
 	if c.Slurm.Timeout == "" {
@@ -96,11 +409,75 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid slurm.timeout format: %w", err)
 	}
 
+	// Validate response size limit
+	if c.Slurm.MaxResponseBytes == 0 {
+		c.Slurm.MaxResponseBytes = DefaultMaxResponseBytes
+	}
+	if c.Slurm.MaxResponseBytes < 0 {
+		return fmt.Errorf("slurm.max_response_bytes must be positive")
+	}
+
+	// Validate compression configuration, in order of preference
+	if len(c.Slurm.Compression) == 0 {
+		c.Slurm.Compression = []string{"zstd", "gzip"}
+	}
+	for _, encoding := range c.Slurm.Compression {
+		switch encoding {
+		case "zstd", "gzip", "identity":
+		default:
+			return fmt.Errorf("invalid slurm.compression %q, expected zstd, gzip or identity", encoding)
+		}
+	}
+
+	// Validate retry configuration
+	if c.Slurm.Retry.MaxAttempts == 0 {
+		c.Slurm.Retry.MaxAttempts = 1
+	}
+	if c.Slurm.Retry.MaxAttempts < 0 {
+		return fmt.Errorf("slurm.retry.max_attempts must be at least 1")
+	}
+	if c.Slurm.Retry.InitialBackoff == "" {
+		c.Slurm.Retry.InitialBackoff = "100ms"
+	}
+	if c.Slurm.Retry.MaxBackoff == "" {
+		c.Slurm.Retry.MaxBackoff = "2s"
+	}
+	if _, err := time.ParseDuration(c.Slurm.Retry.InitialBackoff); err != nil {
+		return fmt.Errorf("invalid slurm.retry.initial_backoff format: %w", err)
+	}
+	if _, err := time.ParseDuration(c.Slurm.Retry.MaxBackoff); err != nil {
+		return fmt.Errorf("invalid slurm.retry.max_backoff format: %w", err)
+	}
+
+	// Validate circuit breaker configuration
+	if c.Slurm.CircuitBreaker.Enabled {
+		if c.Slurm.CircuitBreaker.FailureThreshold == 0 {
+			c.Slurm.CircuitBreaker.FailureThreshold = 5
+		}
+		if c.Slurm.CircuitBreaker.FailureThreshold < 0 {
+			return fmt.Errorf("slurm.circuit_breaker.failure_threshold must be at least 1")
+		}
+		if c.Slurm.CircuitBreaker.OpenDuration == "" {
+			c.Slurm.CircuitBreaker.OpenDuration = "30s"
+		}
+		if _, err := time.ParseDuration(c.Slurm.CircuitBreaker.OpenDuration); err != nil {
+			return fmt.Errorf("invalid slurm.circuit_breaker.open_duration format: %w", err)
+		}
+	}
+
 	// Validate server configuration
 	if c.Server.Port <= 0 || c.Server.Port > 65535 {
 		return fmt.Errorf("server.port must be between 1 and 65535")
 	}
 
+	// Validate readiness configuration
+	if c.Server.ReadyMaxAge == "" {
+		c.Server.ReadyMaxAge = "60s"
+	}
+	if _, err := time.ParseDuration(c.Server.ReadyMaxAge); err != nil {
+		return fmt.Errorf("invalid server.ready_max_age format: %w", err)
+	}
+
 	// Validate Basic Auth configuration
 	if c.Server.BasicAuth.Enabled {
 		if c.Server.BasicAuth.Username == "" || c.Server.BasicAuth.Password == "" {
@@ -108,6 +485,43 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate debug configuration
+	if c.Server.Debug.Endpoints && !c.Server.BasicAuth.Enabled {
+		return fmt.Errorf("server.debug.endpoints requires server.basic_auth to be enabled")
+	}
+	if c.Server.Debug.Pprof && !c.Server.BasicAuth.Enabled {
+		return fmt.Errorf("server.debug.pprof requires server.basic_auth to be enabled")
+	}
+
+	// Validate access log configuration
+	if c.Server.AccessLog.Enabled {
+		if err := c.Server.AccessLog.LogSinkConfig.validate("server.access_log", "stdout"); err != nil {
+			return err
+		}
+	}
+	for _, proxy := range c.Server.AccessLog.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			return fmt.Errorf("invalid server.access_log.trusted_proxies entry %q: expected an IP address or CIDR range", proxy)
+		}
+	}
+
+	// Validate request limits
+	if c.Server.RateLimit.RequestsPerSecond < 0 {
+		return fmt.Errorf("server.rate_limit.requests_per_second must not be negative")
+	}
+	if c.Server.RateLimit.Burst < 0 {
+		return fmt.Errorf("server.rate_limit.burst must not be negative")
+	}
+	if c.Server.RateLimit.RequestsPerSecond > 0 && c.Server.RateLimit.Burst == 0 {
+		c.Server.RateLimit.Burst = int(math.Ceil(c.Server.RateLimit.RequestsPerSecond))
+	}
+	if c.Server.MaxConcurrentScrapes < 0 {
+		return fmt.Errorf("server.max_concurrent_scrapes must not be negative")
+	}
+
 	// Validate SSL configuration
 	if c.Server.SSL.Enabled {
 		if c.Server.SSL.CertFile == "" || c.Server.SSL.KeyFile == "" {
@@ -120,13 +534,125 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("at least one endpoint must be configured")
 	}
 
+	seenEndpoints := make(map[string]bool, len(c.Endpoints))
 	for i, endpoint := range c.Endpoints {
 		if endpoint.Name == "" {
 			return fmt.Errorf("endpoint %d: name is required", i)
 		}
+		if !endpointNamePattern.MatchString(endpoint.Name) {
+			return fmt.Errorf("endpoint %d: invalid name %q, only letters, digits, '_', '.' and '-' are allowed", i, endpoint.Name)
+		}
+		if seenEndpoints[endpoint.Name] {
+			return fmt.Errorf("endpoint %s is defined more than once", endpoint.Name)
+		}
+		seenEndpoints[endpoint.Name] = true
 		if endpoint.Path == "" {
 			return fmt.Errorf("endpoint %d: path is required", i)
 		}
+		if endpoint.MaxSeries < 0 {
+			return fmt.Errorf("endpoint %s: max_series must not be negative", endpoint.Name)
+		}
+		for label, limit := range endpoint.MaxValues {
+			if limit < 1 {
+				return fmt.Errorf("endpoint %s: max_values.%s must be at least 1", endpoint.Name, label)
+			}
+		}
+	}
+
+	// Validate type correction overrides
+	for name, metricType := range c.TypeCorrection.Overrides {
+		if metricType != "counter" && metricType != "gauge" {
+			return fmt.Errorf("type_correction.overrides.%s must be either counter or gauge", name)
+		}
+	}
+
+	// Validate stale series configuration
+	if c.StaleSeries.Enabled {
+		if c.StaleSeries.GracePeriod == "" {
+			c.StaleSeries.GracePeriod = "5m"
+		}
+		if _, err := time.ParseDuration(c.StaleSeries.GracePeriod); err != nil {
+			return fmt.Errorf("invalid stale_series.grace_period format: %w", err)
+		}
+	}
+
+	// Validate enrichment configuration
+	if c.Enrichment.Enabled {
+		if c.Enrichment.File == "" && !c.Enrichment.LDAP.Enabled {
+			return fmt.Errorf("enrichment is enabled but neither file nor ldap is configured")
+		}
+		if err := c.Enrichment.LDAP.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate topology configuration
+	if c.Topology.Enabled && c.Topology.TopologyFile == "" && c.Topology.MappingFile == "" {
+		return fmt.Errorf("topology is enabled but neither topology_file nor mapping_file is configured")
+	}
+
+	// Validate node filter hostlists
+	for _, expr := range c.NodeFilter.Allow {
+		if err := hostlist.Validate(expr); err != nil {
+			return fmt.Errorf("invalid node_filter.allow entry: %w", err)
+		}
+	}
+	for _, expr := range c.NodeFilter.Deny {
+		if err := hostlist.Validate(expr); err != nil {
+			return fmt.Errorf("invalid node_filter.deny entry: %w", err)
+		}
+	}
+
+	// Validate node groups
+	seenGroups := make(map[string]bool, len(c.NodeGroups))
+	for _, group := range c.NodeGroups {
+		if group.Name == "" || group.Members == "" {
+			return fmt.Errorf("node_groups entries require a name and members")
+		}
+		if seenGroups[group.Name] {
+			return fmt.Errorf("node_groups.%s is defined more than once", group.Name)
+		}
+		seenGroups[group.Name] = true
+
+		if group.IsRegex() {
+			if _, err := regexp.Compile(group.AnchoredPattern()); err != nil {
+				return fmt.Errorf("invalid node_groups.%s regex: %w", group.Name, err)
+			}
+		} else if err := hostlist.Validate(group.Members); err != nil {
+			return fmt.Errorf("invalid node_groups.%s hostlist: %w", group.Name, err)
+		}
+	}
+
+	// Validate last known good configuration
+	if c.LastKnownGood.Enabled {
+		if c.LastKnownGood.MaxAge == "" {
+			c.LastKnownGood.MaxAge = "5m"
+		}
+		if _, err := time.ParseDuration(c.LastKnownGood.MaxAge); err != nil {
+			return fmt.Errorf("invalid last_known_good.max_age format: %w", err)
+		}
+	}
+
+	// Validate remote write configuration
+	if c.RemoteWrite.Enabled {
+		if err := c.RemoteWrite.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate OTLP configuration
+	if c.OTLP.Enabled {
+		if err := c.OTLP.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate output formats configuration
+	if strings.ContainsAny(c.Formats.InfluxDB.Measurement, " ,") {
+		return fmt.Errorf("formats.influxdb.measurement must not contain spaces or commas")
+	}
+	if strings.ContainsAny(c.Formats.Graphite.Prefix, " ;") {
+		return fmt.Errorf("formats.graphite.prefix must not contain spaces or semicolons")
 	}
 
 	// Validate logging configuration
@@ -146,8 +672,112 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("logging.level must be one of: debug, info, warn, error")
 	}
 
-	if c.Logging.Output == "" {
+	if c.Logging.Output == "json" {
 		c.Logging.Output = "stdout"
+		if c.Logging.Format == "" {
+			c.Logging.Format = "json"
+		}
+	}
+	if c.Logging.Format == "" {
+		c.Logging.Format = "text"
+	}
+	if c.Logging.Format != "text" && c.Logging.Format != "json" {
+		return fmt.Errorf("logging.format must be one of: text, json")
+	}
+	if err := c.Logging.LogSinkConfig.validate("logging", "stdout"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validate checks the LDAP backend settings and fills in the defaults
+func (l *LDAPConfig) validate() error {
+	if !l.Enabled {
+		return nil
+	}
+
+	if l.URL == "" || l.BaseDN == "" {
+		return fmt.Errorf("enrichment.ldap is enabled but url or base_dn is empty")
+	}
+	if len(l.Attributes) == 0 {
+		return fmt.Errorf("enrichment.ldap is enabled but no attributes are mapped")
+	}
+
+	if l.UserFilter == "" {
+		l.UserFilter = "(uid=%s)"
+	}
+	if strings.Count(l.UserFilter, "%s") != 1 {
+		return fmt.Errorf("enrichment.ldap.user_filter must contain exactly one %%s placeholder")
+	}
+	if l.UsernameAttribute == "" {
+		l.UsernameAttribute = "uid"
+	}
+
+	if l.CacheTTL == "" {
+		l.CacheTTL = "1h"
+	}
+	if _, err := time.ParseDuration(l.CacheTTL); err != nil {
+		return fmt.Errorf("invalid enrichment.ldap.cache_ttl format: %w", err)
+	}
+
+	if l.Timeout == "" {
+		l.Timeout = "5s"
+	}
+	if _, err := time.ParseDuration(l.Timeout); err != nil {
+		return fmt.Errorf("invalid enrichment.ldap.timeout format: %w", err)
+	}
+
+	return nil
+}
+
+// validate checks the remote write settings and fills in the defaults
+func (r *RemoteWriteConfig) validate() error {
+	if r.URL == "" {
+		return fmt.Errorf("remote_write.url is required when remote write is enabled")
+	}
+	if r.Interval == "" {
+		r.Interval = "30s"
+	}
+	if _, err := time.ParseDuration(r.Interval); err != nil {
+		return fmt.Errorf("invalid remote_write.interval format: %w", err)
+	}
+
+	if r.Timeout == "" {
+		r.Timeout = "10s"
+	}
+	if _, err := time.ParseDuration(r.Timeout); err != nil {
+		return fmt.Errorf("invalid remote_write.timeout format: %w", err)
+	}
+
+	if r.QueueSize == 0 {
+		r.QueueSize = 10
+	}
+	if r.QueueSize < 0 {
+		return fmt.Errorf("remote_write.queue_size must be at least 1")
+	}
+
+	if r.BasicAuth.Enabled && (r.BasicAuth.Username == "" || r.BasicAuth.Password == "") {
+		return fmt.Errorf("remote_write.basic_auth is enabled but username or password is empty")
+	}
+
+	if r.Retry.MaxAttempts == 0 {
+		r.Retry.MaxAttempts = 3
+	}
+	if r.Retry.MaxAttempts < 0 {
+		return fmt.Errorf("remote_write.retry.max_attempts must be at least 1")
+	}
+	if r.Retry.InitialBackoff == "" {
+		r.Retry.InitialBackoff = "500ms"
+	}
+	if _, err := time.ParseDuration(r.Retry.InitialBackoff); err != nil {
+		return fmt.Errorf("invalid remote_write.retry.initial_backoff format: %w", err)
+	}
+	if r.Retry.MaxBackoff == "" {
+		r.Retry.MaxBackoff = "10s"
+	}
+	if _, err := time.ParseDuration(r.Retry.MaxBackoff); err != nil {
+		return fmt.Errorf("invalid remote_write.retry.max_backoff format: %w", err)
 	}
 
 	return nil
@@ -158,6 +788,11 @@ func (c *Config) GetTimeoutDuration() (time.Duration, error) {
 	return time.ParseDuration(c.Slurm.Timeout)
 }
 
+// GetStaleGracePeriod returns the stale series grace period as a time.Duration
+func (c *Config) GetStaleGracePeriod() (time.Duration, error) {
+	return time.ParseDuration(c.StaleSeries.GracePeriod)
+}
+
 // GetEnabledEndpoints returns only the enabled endpoints
 func (c *Config) GetEnabledEndpoints() []EndpointConfig {
 	var enabled []EndpointConfig
@@ -168,3 +803,162 @@ func (c *Config) GetEnabledEndpoints() []EndpointConfig {
 	}
 	return enabled
 }
+
+// GetEnabledEndpoint returns the enabled endpoint with the given name
+func (c *Config) GetEnabledEndpoint(name string) (EndpointConfig, bool) {
+	for _, endpoint := range c.Endpoints {
+		if endpoint.Enabled && endpoint.Name == name {
+			return endpoint, true
+		}
+	}
+	return EndpointConfig{}, false
+}
+
+// SyslogFacilities maps the syslog facility names to their codes
+var SyslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// validate checks a log sink and fills in the defaults. Errors name the
+// fields under prefix.
+func (l *LogSinkConfig) validate(prefix, defaultOutput string) error {
+	if l.Output == "" {
+		l.Output = defaultOutput
+	}
+
+	switch l.Output {
+	case "stdout", "stderr":
+	case "file":
+		if l.File.Path == "" {
+			return fmt.Errorf("%s.file.path is required when %s.output is file", prefix, prefix)
+		}
+		if l.File.MaxSizeMB == 0 {
+			l.File.MaxSizeMB = 100
+		}
+		if l.File.MaxSizeMB < 0 {
+			return fmt.Errorf("%s.file.max_size_mb must be at least 1", prefix)
+		}
+		if l.File.MaxBackups == 0 {
+			l.File.MaxBackups = 5
+		}
+		if l.File.MaxBackups < 0 {
+			return fmt.Errorf("%s.file.max_backups must be at least 1", prefix)
+		}
+	case "syslog":
+		if l.Syslog.Socket == "" {
+			l.Syslog.Socket = "/dev/log"
+		}
+		if l.Syslog.Facility == "" {
+			l.Syslog.Facility = "daemon"
+		}
+		if _, ok := SyslogFacilities[l.Syslog.Facility]; !ok {
+			return fmt.Errorf("%s.syslog.facility %q is not a syslog facility", prefix, l.Syslog.Facility)
+		}
+		if l.Syslog.Tag == "" {
+			l.Syslog.Tag = "slurm_exporter"
+		}
+	case "journald":
+		if l.Journald.Socket == "" {
+			l.Journald.Socket = "/run/systemd/journal/socket"
+		}
+		if l.Journald.Identifier == "" {
+			l.Journald.Identifier = "slurm_exporter"
+		}
+	default:
+		return fmt.Errorf("%s.output must be one of: stdout, stderr, file, syslog, journald", prefix)
+	}
+
+	return nil
+}
+
+// redactedValue replaces secrets in Redacted
+const redactedValue = "<secret>"
+
+// Redacted returns a copy of the configuration safe to display: passwords,
+// header values and credentials embedded in URLs are replaced
+func (c *Config) Redacted() *Config {
+	redacted := *c
+
+	redacted.Slurm.URL = redactURL(c.Slurm.URL)
+	redacted.Slurm.URLs = make([]string, len(c.Slurm.URLs))
+	for i, raw := range c.Slurm.URLs {
+		redacted.Slurm.URLs[i] = redactURL(raw)
+	}
+
+	redacted.Server.BasicAuth.Password = redactSecret(c.Server.BasicAuth.Password)
+	redacted.Enrichment.LDAP.BindPassword = redactSecret(c.Enrichment.LDAP.BindPassword)
+
+	redacted.RemoteWrite.URL = redactURL(c.RemoteWrite.URL)
+	redacted.RemoteWrite.BasicAuth.Password = redactSecret(c.RemoteWrite.BasicAuth.Password)
+	redacted.RemoteWrite.Headers = redactHeaders(c.RemoteWrite.Headers)
+
+	redacted.OTLP.Endpoint = redactURL(c.OTLP.Endpoint)
+	redacted.OTLP.Headers = redactHeaders(c.OTLP.Headers)
+
+	return &redacted
+}
+
+// redactSecret hides a non-empty secret
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// redactHeaders hides every header value, since headers commonly carry tokens
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		redacted[name] = redactSecret(value)
+	}
+	return redacted
+}
+
+// redactURL hides the password of a URL carrying user information
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return parsed.Redacted()
+}
+
+// validate checks the OTLP settings and fills in the defaults
+func (o *OTLPConfig) validate() error {
+	if o.Protocol == "" {
+		o.Protocol = "http"
+	}
+	if o.Protocol != "http" && o.Protocol != "grpc" {
+		return fmt.Errorf("otlp.protocol must be one of: http, grpc")
+	}
+
+	if o.Endpoint == "" {
+		return fmt.Errorf("otlp.endpoint is required when OTLP export is enabled")
+	}
+	if o.Protocol == "http" && !strings.HasPrefix(o.Endpoint, "http://") && !strings.HasPrefix(o.Endpoint, "https://") {
+		return fmt.Errorf("otlp.endpoint must be an http:// or https:// URL when otlp.protocol is http")
+	}
+
+	if o.Interval == "" {
+		o.Interval = "30s"
+	}
+	if _, err := time.ParseDuration(o.Interval); err != nil {
+		return fmt.Errorf("invalid otlp.interval format: %w", err)
+	}
+
+	if o.Timeout == "" {
+		o.Timeout = "10s"
+	}
+	if _, err := time.ParseDuration(o.Timeout); err != nil {
+		return fmt.Errorf("invalid otlp.timeout format: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package enrichment
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// Labels are the labels the enrichment stage may add to a series
+var Labels = []string{"department", "project", "pi"}
+
+// Label names whose values are joined against the mapping
+const (
+	usernameLabel = "username"
+	accountLabel  = "account"
+)
+
+// isSupportedLabel reports whether label is one of the enrichment labels
+func isSupportedLabel(label string) bool {
+	for _, l := range Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// Enricher adds department, project and pi labels to series carrying a
+// username or account label
+type Enricher struct {
+	file   string
+	ldap   *ldapSource
+	logger *slog.Logger
+
+	mu      sync.RWMutex
+	mapping *Mapping
+	modTime time.Time
+	size    int64
+}
+
+// New creates an enricher and loads the mapping file if one is configured
+func New(cfg config.EnrichmentConfig, logger *slog.Logger) (*Enricher, error) {
+	e := &Enricher{
+		file:    cfg.File,
+		logger:  logger,
+		mapping: &Mapping{},
+	}
+
+	if cfg.File != "" {
+		if err := e.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.LDAP.Enabled {
+		for label := range cfg.LDAP.Attributes {
+			if !isSupportedLabel(label) {
+				return nil, fmt.Errorf("enrichment.ldap.attributes: unsupported label %q", label)
+			}
+		}
+
+		source, err := newLDAPSource(cfg.LDAP, logger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ldap configuration: %w", err)
+		}
+		e.ldap = source
+	}
+
+	return e, nil
+}
+
+// Close stops the background LDAP lookups
+func (e *Enricher) Close() {
+	if e.ldap != nil {
+		e.ldap.close()
+	}
+}
+
+// load reads the mapping file and records its modification time
+func (e *Enricher) load() error {
+	info, err := os.Stat(e.file)
+	if err != nil {
+		return fmt.Errorf("failed to stat mapping file: %w", err)
+	}
+
+	mapping, err := LoadMapping(e.file)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.mapping = mapping
+	e.modTime = info.ModTime()
+	e.size = info.Size()
+	e.mu.Unlock()
+
+	e.logger.Info("loaded enrichment mapping",
+		"file", e.file,
+		"users", len(mapping.Users),
+		"accounts", len(mapping.Accounts))
+
+	return nil
+}
+
+// reloadIfChanged reloads the mapping file when its modification time or size
+// changed. A file that fails to load keeps the previous mapping in place.
+func (e *Enricher) reloadIfChanged() {
+	if e.file == "" {
+		return
+	}
+
+	info, err := os.Stat(e.file)
+	if err != nil {
+		e.logger.Warn("failed to stat enrichment mapping, keeping previous mapping", "file", e.file, "error", err)
+		return
+	}
+
+	e.mu.RLock()
+	changed := !info.ModTime().Equal(e.modTime) || info.Size() != e.size
+	e.mu.RUnlock()
+
+	if !changed {
+		return
+	}
+
+	if err := e.load(); err != nil {
+		e.logger.Warn("failed to reload enrichment mapping, keeping previous mapping", "file", e.file, "error", err)
+	}
+}
+
+// Enrich adds the enrichment labels to every sample carrying a username or
+// account label. Labels already present on a sample are left untouched.
+func (e *Enricher) Enrich(families []*openmetrics.Family) {
+	e.reloadIfChanged()
+
+	e.mu.RLock()
+	mapping := e.mapping
+	e.mu.RUnlock()
+
+	var fromLDAP map[string]Attributes
+	if e.ldap != nil {
+		fromLDAP = e.ldap.lookup(unmappedUsers(families, mapping))
+	}
+
+	for _, family := range families {
+		for i := range family.Samples {
+			sample := &family.Samples[i]
+
+			if username, ok := sample.Label(usernameLabel); ok {
+				attributes, found := mapping.Users[username]
+				if !found {
+					attributes = fromLDAP[username]
+				}
+				applyAttributes(sample, attributes)
+			}
+
+			if account, ok := sample.Label(accountLabel); ok {
+				applyAttributes(sample, mapping.Accounts[account])
+			}
+		}
+	}
+}
+
+// unmappedUsers returns the distinct usernames missing from the mapping file
+func unmappedUsers(families []*openmetrics.Family, mapping *Mapping) []string {
+	seen := make(map[string]bool)
+	for _, family := range families {
+		for i := range family.Samples {
+			username, ok := family.Samples[i].Label(usernameLabel)
+			if !ok || seen[username] {
+				continue
+			}
+			if _, mapped := mapping.Users[username]; !mapped {
+				seen[username] = true
+			}
+		}
+	}
+
+	usernames := make([]string, 0, len(seen))
+	for username := range seen {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	return usernames
+}
+
+// applyAttributes sets the enrichment labels missing from the sample
+func applyAttributes(sample *openmetrics.Sample, attributes Attributes) {
+	for _, label := range Labels {
+		value, ok := attributes[label]
+		if !ok || value == "" {
+			continue
+		}
+		if _, exists := sample.Label(label); !exists {
+			sample.Labels = append(sample.Labels, openmetrics.Label{Name: label, Value: value})
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package enrichment
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+const accountJobs = `# HELP slurm_user_jobs_running Number of jobs in Running state
+# TYPE slurm_user_jobs_running gauge
+slurm_user_jobs_running{username="alice"} 1
+slurm_user_jobs_running{username="bob"} 2
+# HELP slurm_account_jobs_running Number of jobs in Running state
+# TYPE slurm_account_jobs_running gauge
+slurm_account_jobs_running{account="physics"} 3
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func enrich(t *testing.T, e *Enricher) string {
+	t.Helper()
+	families, err := openmetrics.Parse(strings.NewReader(accountJobs))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	e.Enrich(families)
+
+	var out strings.Builder
+	if err := openmetrics.Write(&out, families); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	return out.String()
+}
+
+func TestEnrichYAML(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "mapping.yaml", `
+users:
+  alice:
+    department: physics
+    project: climate
+    pi: carol
+accounts:
+  physics:
+    department: physics
+    pi: carol
+`)
+
+	e, err := New(config.EnrichmentConfig{Enabled: true, File: path}, testLogger)
+	if err != nil {
+		t.Fatalf("Failed to create enricher: %v", err)
+	}
+
+	out := enrich(t, e)
+	for _, want := range []string{
+		`slurm_user_jobs_running{username="alice",department="physics",project="climate",pi="carol"} 1`,
+		`slurm_user_jobs_running{username="bob"} 2`,
+		`slurm_account_jobs_running{account="physics",department="physics",pi="carol"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestEnrichCSVReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "mapping.csv", "type,name,department,project,pi\nuser,alice,physics,,carol\n")
+
+	e, err := New(config.EnrichmentConfig{Enabled: true, File: path}, testLogger)
+	if err != nil {
+		t.Fatalf("Failed to create enricher: %v", err)
+	}
+
+	if out := enrich(t, e); !strings.Contains(out, `{username="alice",department="physics",pi="carol"} 1`) {
+		t.Errorf("Unexpected output:\n%s", out)
+	}
+
+	// Rewrite the file with a different modification time
+	writeFile(t, dir, "mapping.csv", "type,name,department,project,pi\nuser,bob,chemistry,catalysis,dave\n")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Failed to touch mapping: %v", err)
+	}
+
+	out := enrich(t, e)
+	if !strings.Contains(out, `{username="bob",department="chemistry",project="catalysis",pi="dave"} 2`) {
+		t.Errorf("Expected reloaded mapping to apply, got:\n%s", out)
+	}
+	if !strings.Contains(out, `{username="alice"} 1`) {
+		t.Errorf("Expected alice to lose the enrichment labels after reload, got:\n%s", out)
+	}
+
+	// A broken file keeps the previous mapping
+	writeFile(t, dir, "mapping.csv", "broken")
+	if err := os.Chtimes(path, later.Add(time.Minute), later.Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to touch mapping: %v", err)
+	}
+	if out := enrich(t, e); !strings.Contains(out, `department="chemistry"`) {
+		t.Errorf("Expected previous mapping to be kept, got:\n%s", out)
+	}
+}
+
+func TestLoadMappingInvalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"unknown-label.yaml": "users:\n  alice:\n    email: alice@example.org\n",
+		"bad-type.csv":       "type,name,department\ngroup,alice,physics\n",
+		"bad-header.csv":     "name,department\nalice,physics\n",
+	}
+
+	for name, content := range tests {
+		if _, err := LoadMapping(writeFile(t, dir, name, content)); err == nil {
+			t.Errorf("Expected an error loading %s", name)
+		}
+	}
+}
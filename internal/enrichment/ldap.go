@@ -0,0 +1,309 @@
+package enrichment
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+// ldapEntry is a cached lookup result, empty when the user was not found
+type ldapEntry struct {
+	attributes Attributes
+	expires    time.Time
+}
+
+// LDAP refresh settings: usernames are searched in batches, and after a
+// failure the directory is not contacted again before a backoff, doubling
+// from ldapRetryMin up to ldapRetryMax
+const (
+	ldapBatchSize = 100
+	ldapRetryMin  = 5 * time.Second
+	ldapRetryMax  = 5 * time.Minute
+)
+
+// ldapSource resolves usernames against an LDAP directory. Scrapes are only
+// served from the cache; unknown and expired users are queued and searched by
+// a background refresher, so a slow or unreachable directory never delays a
+// scrape. Entries that could not be refreshed within another TTL after they
+// expired are evicted, so a directory change or a departed user is never
+// served for longer than twice the TTL.
+type ldapSource struct {
+	config  config.LDAPConfig
+	ttl     time.Duration
+	timeout time.Duration
+	logger  *slog.Logger
+
+	mu       sync.Mutex
+	cache    map[string]ldapEntry
+	pending  map[string]bool
+	failures int
+	retryAt  time.Time
+
+	start   sync.Once
+	stop    sync.Once
+	running sync.WaitGroup
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+// newLDAPSource creates an LDAP lookup backend from the configuration
+func newLDAPSource(cfg config.LDAPConfig, logger *slog.Logger) (*ldapSource, error) {
+	ttl, err := time.ParseDuration(cfg.CacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache_ttl: %w", err)
+	}
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	return &ldapSource{
+		config:  cfg,
+		ttl:     ttl,
+		timeout: timeout,
+		logger:  logger,
+		cache:   make(map[string]ldapEntry),
+		pending: make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// lookup returns the cached attributes of the given usernames without
+// contacting the directory. Users missing from the cache, or whose entry
+// expired, are queued for the refresher; expired entries are still served
+// until they are replaced or evicted. Missing users are cached as empty
+// entries.
+func (l *ldapSource) lookup(usernames []string) map[string]Attributes {
+	now := time.Now()
+	results := make(map[string]Attributes, len(usernames))
+	queued := false
+
+	l.mu.Lock()
+	for _, username := range usernames {
+		entry, ok := l.cache[username]
+		if ok && l.evictable(entry, now) {
+			delete(l.cache, username)
+			ok = false
+		}
+		if ok {
+			results[username] = entry.attributes
+		}
+		if !ok || !now.Before(entry.expires) {
+			l.pending[username] = true
+			queued = true
+		}
+	}
+	l.mu.Unlock()
+
+	if queued {
+		l.start.Do(func() {
+			l.running.Add(1)
+			go l.run()
+		})
+		select {
+		case l.wake <- struct{}{}:
+		default:
+		}
+	}
+	return results
+}
+
+// evictable reports whether an entry expired more than a TTL ago
+func (l *ldapSource) evictable(entry ldapEntry, now time.Time) bool {
+	return now.Sub(entry.expires) > l.ttl
+}
+
+// run refreshes the queued users whenever a scrape queues some, waiting for
+// the backoff after a failure, until the source is closed
+func (l *ldapSource) run() {
+	defer l.running.Done()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-l.wake:
+		}
+
+		l.mu.Lock()
+		wait := time.Until(l.retryAt)
+		l.mu.Unlock()
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-l.done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		l.refresh()
+	}
+}
+
+// close stops the refresher, waiting for a running refresh to complete
+func (l *ldapSource) close() {
+	l.stop.Do(func() { close(l.done) })
+	l.running.Wait()
+}
+
+// refresh searches the queued users in batches over a single connection.
+// Users stay queued until their batch was searched, so a failure leaves them
+// for the next attempt.
+func (l *ldapSource) refresh() {
+	l.evict(time.Now())
+
+	l.mu.Lock()
+	usernames := make([]string, 0, len(l.pending))
+	for username := range l.pending {
+		usernames = append(usernames, username)
+	}
+	l.mu.Unlock()
+	if len(usernames) == 0 {
+		return
+	}
+	sort.Strings(usernames)
+
+	conn, err := l.connect()
+	if err != nil {
+		l.fail("ldap connection failed", err)
+		return
+	}
+	defer conn.Close()
+
+	for start := 0; start < len(usernames); start += ldapBatchSize {
+		select {
+		case <-l.done:
+			return
+		default:
+		}
+
+		batch := usernames[start:min(start+ldapBatchSize, len(usernames))]
+		found, err := l.search(conn, batch)
+		if err != nil {
+			l.fail("ldap search failed", err)
+			return
+		}
+
+		expires := time.Now().Add(l.ttl)
+		l.mu.Lock()
+		for _, username := range batch {
+			l.cache[username] = ldapEntry{attributes: found[username], expires: expires}
+			delete(l.pending, username)
+		}
+		l.failures = 0
+		l.mu.Unlock()
+	}
+}
+
+// evict removes the entries that expired more than a TTL ago, such as users
+// no longer seen in any scrape
+func (l *ldapSource) evict(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for username, entry := range l.cache {
+		if l.evictable(entry, now) {
+			delete(l.cache, username)
+		}
+	}
+}
+
+// fail records a failed refresh and schedules the next attempt
+func (l *ldapSource) fail(msg string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	backoff := ldapRetryMax
+	if l.failures < 7 {
+		backoff = min(ldapRetryMin<<l.failures, ldapRetryMax)
+	}
+	l.failures++
+	l.retryAt = time.Now().Add(backoff)
+
+	l.logger.Warn(msg,
+		"url", l.config.URL,
+		"queued_users", len(l.pending),
+		"retry_in", backoff,
+		"error", err)
+}
+
+// connect dials and binds to the directory
+func (l *ldapSource) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(l.config.URL, ldap.DialWithDialer(&net.Dialer{Timeout: l.timeout}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	conn.SetTimeout(l.timeout)
+
+	if l.config.BindDN != "" {
+		if err := conn.Bind(l.config.BindDN, l.config.BindPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to bind as %s: %w", l.config.BindDN, err)
+		}
+	}
+
+	return conn, nil
+}
+
+// search looks up a batch of users with a single (|filter...) search and
+// maps their LDAP attributes to labels. Users without an entry get empty
+// attributes.
+func (l *ldapSource) search(conn *ldap.Conn, usernames []string) (map[string]Attributes, error) {
+	ldapAttributes := []string{l.config.UsernameAttribute}
+	for _, attribute := range l.config.Attributes {
+		ldapAttributes = append(ldapAttributes, attribute)
+	}
+
+	var filter strings.Builder
+	filter.WriteString("(|")
+	for _, username := range usernames {
+		filter.WriteString(fmt.Sprintf(l.config.UserFilter, ldap.EscapeFilter(username)))
+	}
+	filter.WriteString(")")
+
+	request := ldap.NewSearchRequest(
+		l.config.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		int(l.timeout.Seconds()),
+		false,
+		filter.String(),
+		ldapAttributes,
+		nil,
+	)
+
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Directory string matching is usually case-insensitive
+	requested := make(map[string]string, len(usernames))
+	results := make(map[string]Attributes, len(usernames))
+	for _, username := range usernames {
+		requested[strings.ToLower(username)] = username
+		results[username] = make(Attributes)
+	}
+
+	for _, entry := range result.Entries {
+		username, ok := requested[strings.ToLower(entry.GetAttributeValue(l.config.UsernameAttribute))]
+		if !ok || len(results[username]) > 0 {
+			continue
+		}
+		for label, attribute := range l.config.Attributes {
+			if value := entry.GetAttributeValue(attribute); value != "" {
+				results[username][label] = value
+			}
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,424 @@
+package enrichment
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+// uidFilter extracts the usernames of a (|(uid=a)(uid=b)...) filter
+var uidFilter = regexp.MustCompile(`\(uid=([^)]*)\)`)
+
+// fakeLDAPServer answers bind and search requests from an in-memory directory
+type fakeLDAPServer struct {
+	listener net.Listener
+	users    map[string]map[string]string
+
+	mu       sync.Mutex
+	searches int
+}
+
+func newFakeLDAPServer(t *testing.T, users map[string]map[string]string) *fakeLDAPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	s := &fakeLDAPServer{listener: listener, users: users}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeLDAPServer) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *fakeLDAPServer) searchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.searches
+}
+
+func (s *fakeLDAPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeLDAPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			conn.Write(response(messageID, ldap.ApplicationBindResponse).Bytes())
+		case ldap.ApplicationSearchRequest:
+			s.mu.Lock()
+			s.searches++
+			s.mu.Unlock()
+
+			filter, _ := ldap.DecompileFilter(request.Children[6])
+			for _, match := range uidFilter.FindAllStringSubmatch(filter, -1) {
+				uid := match[1]
+				if attributes, ok := s.users[uid]; ok {
+					attributes["uid"] = uid
+					conn.Write(entry(messageID, "uid="+uid+",ou=people,dc=example,dc=org", attributes).Bytes())
+				}
+			}
+			conn.Write(response(messageID, ldap.ApplicationSearchResultDone).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+func envelope(messageID int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	return packet
+}
+
+func response(messageID int64, tag ber.Tag) *ber.Packet {
+	packet := envelope(messageID)
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, 0, "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	packet.AppendChild(op)
+	return packet
+}
+
+func entry(messageID int64, dn string, attributes map[string]string) *ber.Packet {
+	packet := envelope(messageID)
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "objectName"))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, value := range attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		attribute.AppendChild(values)
+		list.AppendChild(attribute)
+	}
+	op.AppendChild(list)
+	packet.AppendChild(op)
+	return packet
+}
+
+func TestEnrichLDAP(t *testing.T) {
+	server := newFakeLDAPServer(t, map[string]map[string]string{
+		"bob": {"departmentNumber": "chemistry", "manager": "dave"},
+	})
+
+	dir := t.TempDir()
+	path := writeFile(t, dir, "mapping.yaml", "users:\n  alice:\n    department: physics\n")
+
+	cfg := config.EnrichmentConfig{
+		Enabled: true,
+		File:    path,
+		LDAP: config.LDAPConfig{
+			Enabled:           true,
+			URL:               server.url(),
+			BindDN:            "cn=exporter,dc=example,dc=org",
+			BindPassword:      "secret",
+			BaseDN:            "ou=people,dc=example,dc=org",
+			UserFilter:        "(uid=%s)",
+			Attributes:        map[string]string{"department": "departmentNumber", "pi": "manager"},
+			CacheTTL:          "1h",
+			Timeout:           "2s",
+			UsernameAttribute: "uid",
+		},
+	}
+
+	e, err := New(cfg, testLogger)
+	if err != nil {
+		t.Fatalf("Failed to create enricher: %v", err)
+	}
+	defer e.Close()
+
+	// The first scrape is not delayed by the directory: bob is enriched once
+	// the background refresh completed
+	out := enrich(t, e)
+	if !strings.Contains(out, `{username="alice",department="physics"} 1`) {
+		t.Errorf("Expected alice to be enriched from the file, got:\n%s", out)
+	}
+	out = eventually(t, e, `{username="bob",department="chemistry",pi="dave"} 2`)
+
+	// Later scrapes are served from the cache
+	enrich(t, e)
+	if count := server.searchCount(); count != 1 {
+		t.Errorf("Expected 1 LDAP search, got %d", count)
+	}
+}
+
+// eventually enriches the test series until the output contains want
+func eventually(t *testing.T, e *Enricher, want string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		out := enrich(t, e)
+		if strings.Contains(out, want) {
+			return out
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s in the output, got:\n%s", want, out)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLDAPBatchedSearch(t *testing.T) {
+	users := make(map[string]map[string]string)
+	var usernames []string
+	for i := 0; i < 250; i++ {
+		username := fmt.Sprintf("user%03d", i)
+		users[username] = map[string]string{"departmentNumber": "dept-" + username}
+		usernames = append(usernames, username)
+	}
+	server := newFakeLDAPServer(t, users)
+
+	source, err := newLDAPSource(config.LDAPConfig{
+		URL:               server.url(),
+		BaseDN:            "ou=people,dc=example,dc=org",
+		UserFilter:        "(uid=%s)",
+		UsernameAttribute: "uid",
+		Attributes:        map[string]string{"department": "departmentNumber"},
+		CacheTTL:          "1h",
+		Timeout:           "2s",
+	}, testLogger)
+	if err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	defer source.close()
+
+	lookup := append(usernames, "nobody")
+	if results := source.lookup(lookup); len(results) != 0 {
+		t.Errorf("Expected an empty cache on the first lookup, got %d users", len(results))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		results := source.lookup(lookup)
+		if len(results) == len(lookup) {
+			if results["nobody"]["department"] != "" || results[usernames[42]]["department"] != "dept-"+usernames[42] {
+				t.Errorf("Unexpected results for nobody %v and %s %v", results["nobody"], usernames[42], results[usernames[42]])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected every user to be resolved, got %d of %d", len(results), len(lookup))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 251 users in batches of 100
+	if count := server.searchCount(); count != 3 {
+		t.Errorf("Expected 3 batched searches, got %d", count)
+	}
+}
+
+func TestLDAPBackoff(t *testing.T) {
+	// A directory accepting connections but never answering the bind
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	var connections atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			connections.Add(1)
+			defer conn.Close()
+		}
+	}()
+
+	source, err := newLDAPSource(config.LDAPConfig{
+		URL:               "ldap://" + listener.Addr().String(),
+		BindDN:            "cn=exporter,dc=example,dc=org",
+		BindPassword:      "secret",
+		BaseDN:            "ou=people,dc=example,dc=org",
+		UserFilter:        "(uid=%s)",
+		UsernameAttribute: "uid",
+		Attributes:        map[string]string{"department": "departmentNumber"},
+		CacheTTL:          "1h",
+		Timeout:           "100ms",
+	}, testLogger)
+	if err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	defer source.close()
+
+	start := time.Now()
+	source.lookup([]string{"bob"})
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected the lookup not to wait for the directory, took %v", elapsed)
+	}
+
+	// Wait for the failed bind to be recorded
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		source.mu.Lock()
+		failures := source.failures
+		source.mu.Unlock()
+		if failures == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the failed bind to be recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Further scrapes during the backoff do not reach the directory
+	for i := 0; i < 5; i++ {
+		source.lookup([]string{"bob", "carol"})
+		time.Sleep(20 * time.Millisecond)
+	}
+	if count := connections.Load(); count != 1 {
+		t.Errorf("Expected a single connection during the backoff, got %d", count)
+	}
+}
+
+func TestEnrichLDAPUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	url := "ldap://" + listener.Addr().String()
+	listener.Close()
+
+	cfg := config.EnrichmentConfig{
+		Enabled: true,
+		LDAP: config.LDAPConfig{
+			Enabled:    true,
+			URL:        url,
+			BaseDN:     "ou=people,dc=example,dc=org",
+			UserFilter: "(uid=%s)",
+			Attributes: map[string]string{"department": "departmentNumber"},
+			CacheTTL:   "1h",
+			Timeout:    "1s",
+		},
+	}
+
+	e, err := New(cfg, testLogger)
+	if err != nil {
+		t.Fatalf("Failed to create enricher: %v", err)
+	}
+	defer e.Close()
+
+	if out := enrich(t, e); !strings.Contains(out, `{username="bob"} 2`) {
+		t.Errorf("Expected series to be exported unchanged, got:\n%s", out)
+	}
+}
+
+func TestLDAPClose(t *testing.T) {
+	// A directory refusing connections puts the refresher in its backoff
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	url := "ldap://" + listener.Addr().String()
+	listener.Close()
+
+	source, err := newLDAPSource(config.LDAPConfig{
+		URL:               url,
+		BaseDN:            "ou=people,dc=example,dc=org",
+		UserFilter:        "(uid=%s)",
+		UsernameAttribute: "uid",
+		CacheTTL:          "1h",
+		Timeout:           "1s",
+	}, testLogger)
+	if err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+
+	source.lookup([]string{"bob"})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		source.mu.Lock()
+		failures := source.failures
+		source.mu.Unlock()
+		if failures > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the failed connection to be recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The refresher now waits for the end of the backoff
+	source.lookup([]string{"bob"})
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	source.close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected close not to wait for the backoff, took %v", elapsed)
+	}
+}
+
+func TestLDAPCacheEviction(t *testing.T) {
+	source, err := newLDAPSource(config.LDAPConfig{
+		URL:               "ldap://127.0.0.1:1",
+		BaseDN:            "ou=people,dc=example,dc=org",
+		UserFilter:        "(uid=%s)",
+		UsernameAttribute: "uid",
+		CacheTTL:          "1m",
+		Timeout:           "1s",
+	}, testLogger)
+	if err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	defer source.close()
+
+	now := time.Now()
+	source.cache["alice"] = ldapEntry{attributes: Attributes{"department": "physics"}, expires: now.Add(-30 * time.Second)}
+	source.cache["bob"] = ldapEntry{attributes: Attributes{"department": "chemistry"}, expires: now.Add(-2 * time.Minute)}
+	source.cache["carol"] = ldapEntry{attributes: Attributes{"department": "biology"}, expires: now.Add(-2 * time.Minute)}
+
+	// Expired entries are served while they are refreshed, but not once
+	// they are more than a TTL past their expiry
+	results := source.lookup([]string{"alice", "bob"})
+	if results["alice"]["department"] != "physics" {
+		t.Errorf("Expected the recently expired entry to be served, got %v", results["alice"])
+	}
+	if _, ok := results["bob"]; ok {
+		t.Errorf("Expected the old entry to be evicted, got %v", results["bob"])
+	}
+
+	// Users no longer seen are evicted too
+	source.evict(now)
+	source.mu.Lock()
+	_, ok := source.cache["carol"]
+	source.mu.Unlock()
+	if ok {
+		t.Errorf("Expected carol to be evicted")
+	}
+}
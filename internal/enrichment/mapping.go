@@ -0,0 +1,117 @@
+package enrichment
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Attributes are the enrichment labels attached to a username or account
+type Attributes map[string]string
+
+// Mapping holds the enrichment attributes keyed by username and account
+type Mapping struct {
+	Users    map[string]Attributes `yaml:"users"`
+	Accounts map[string]Attributes `yaml:"accounts"`
+}
+
+// LoadMapping reads a mapping file. Files ending in .csv are read as CSV with
+// a "type,name,<label>..." header, anything else is read as YAML.
+func LoadMapping(path string) (*Mapping, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mapping file: %w", err)
+	}
+	defer file.Close()
+
+	var mapping *Mapping
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		mapping, err = parseCSV(file)
+	} else {
+		mapping, err = parseYAML(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mapping file %s: %w", path, err)
+	}
+
+	if err := mapping.validate(); err != nil {
+		return nil, fmt.Errorf("invalid mapping file %s: %w", path, err)
+	}
+
+	return mapping, nil
+}
+
+// parseYAML reads a mapping with "users" and "accounts" sections
+func parseYAML(r io.Reader) (*Mapping, error) {
+	var mapping Mapping
+	if err := yaml.NewDecoder(r).Decode(&mapping); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// parseCSV reads rows of the form "user,alice,physics,climate,bob"
+func parseCSV(r io.Reader) (*Mapping, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if len(header) < 3 || header[0] != "type" || header[1] != "name" {
+		return nil, fmt.Errorf("header must start with type,name followed by at least one label")
+	}
+
+	mapping := &Mapping{
+		Users:    make(map[string]Attributes),
+		Accounts: make(map[string]Attributes),
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return mapping, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		attributes := make(Attributes, len(header)-2)
+		for i, label := range header[2:] {
+			if value := record[i+2]; value != "" {
+				attributes[label] = value
+			}
+		}
+
+		switch record[0] {
+		case "user":
+			mapping.Users[record[1]] = attributes
+		case "account":
+			mapping.Accounts[record[1]] = attributes
+		default:
+			return nil, fmt.Errorf("unknown type %q for %s, expected user or account", record[0], record[1])
+		}
+	}
+}
+
+// validate ensures the mapping only sets the supported enrichment labels
+func (m *Mapping) validate() error {
+	for kind, entries := range map[string]map[string]Attributes{"users": m.Users, "accounts": m.Accounts} {
+		for name, attributes := range entries {
+			for label := range attributes {
+				if !isSupportedLabel(label) {
+					return fmt.Errorf("%s.%s: unsupported label %q, expected one of %s",
+						kind, name, label, strings.Join(Labels, ", "))
+				}
+			}
+		}
+	}
+	return nil
+}
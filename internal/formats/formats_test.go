@@ -0,0 +1,101 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+const testPayload = `# TYPE slurm_node_cpus gauge
+slurm_node_cpus{node="c001",partition="gpu a100",state=""} 64
+slurm_node_cpus{node="c002",partition="cpu"} 32 1700000000000
+slurm_node_cpus{node="c003",partition="cpu"} NaN
+# TYPE slurm_jobs_submitted counter
+slurm_jobs_submitted_total 1200
+`
+
+func parse(t *testing.T) []*openmetrics.Family {
+	t.Helper()
+	families, err := openmetrics.Parse(strings.NewReader(testPayload))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	return families
+}
+
+func TestWriteInfluxDB(t *testing.T) {
+	now := time.Unix(2000, 0)
+
+	tests := []struct {
+		name string
+		cfg  config.InfluxDBFormatConfig
+		want string
+	}{
+		{
+			name: "measurement per metric",
+			cfg:  config.InfluxDBFormatConfig{},
+			want: `slurm_node_cpus,node=c001,partition=gpu\ a100 value=64 2000000000000
+slurm_node_cpus,node=c002,partition=cpu value=32 1700000000000000000
+slurm_jobs_submitted_total value=1200 2000000000000
+`,
+		},
+		{
+			name: "fixed measurement",
+			cfg:  config.InfluxDBFormatConfig{Measurement: "slurm", TrimPrefix: "slurm_"},
+			want: `slurm,node=c001,partition=gpu\ a100 node_cpus=64 2000000000000
+slurm,node=c002,partition=cpu node_cpus=32 1700000000000000000
+slurm jobs_submitted_total=1200 2000000000000
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		if err := WriteInfluxDB(&out, parse(t), tt.cfg, now); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tt.name, tt.want, out.String())
+		}
+	}
+}
+
+func TestWriteGraphite(t *testing.T) {
+	now := time.Unix(2000, 0)
+
+	tests := []struct {
+		name string
+		cfg  config.GraphiteFormatConfig
+		want string
+	}{
+		{
+			name: "labels in path",
+			cfg:  config.GraphiteFormatConfig{Prefix: "hpc", TrimPrefix: "slurm_"},
+			want: `hpc.node_cpus.c001.gpu_a100 64 2000
+hpc.node_cpus.c002.cpu 32 1700000000
+hpc.jobs_submitted_total 1200 2000
+`,
+		},
+		{
+			name: "tagged",
+			cfg:  config.GraphiteFormatConfig{Tagged: true},
+			want: `slurm_node_cpus;node=c001;partition=gpu_a100 64 2000
+slurm_node_cpus;node=c002;partition=cpu 32 1700000000
+slurm_jobs_submitted_total 1200 2000
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		if err := WriteGraphite(&out, parse(t), tt.cfg, now); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tt.name, tt.want, out.String())
+		}
+	}
+}
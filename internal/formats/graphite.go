@@ -0,0 +1,79 @@
+package formats
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// WriteGraphite writes every sample as a Graphite plaintext line with a
+// timestamp in seconds. Samples without a timestamp are stamped with now; NaN
+// and infinite values are skipped.
+func WriteGraphite(w io.Writer, families []*openmetrics.Family, cfg config.GraphiteFormatConfig, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		for i := range family.Samples {
+			sample := &family.Samples[i]
+			if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+				continue
+			}
+
+			path := graphiteNode(strings.TrimPrefix(sample.Name, cfg.TrimPrefix))
+			if cfg.Prefix != "" {
+				path = cfg.Prefix + "." + path
+			}
+			bw.WriteString(path)
+
+			for _, label := range sortedLabels(sample.Labels) {
+				if label.Value == "" {
+					continue
+				}
+				if cfg.Tagged {
+					bw.WriteByte(';')
+					bw.WriteString(graphiteNode(label.Name))
+					bw.WriteByte('=')
+					bw.WriteString(graphiteTagValue(label.Value))
+				} else {
+					bw.WriteByte('.')
+					bw.WriteString(graphiteNode(label.Value))
+				}
+			}
+
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.FormatFloat(sample.Value, 'g', -1, 64))
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.FormatInt(timestamp(sample, now).Unix(), 10))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// graphiteNode replaces the characters that would split or break a path
+// node, keeping letters, digits, '_', '-' and ':'
+func graphiteNode(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == ':':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// graphiteTagValue replaces the characters not allowed in a tag value
+func graphiteTagValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ';', '~', ' ', '\t', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
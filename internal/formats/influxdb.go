@@ -0,0 +1,79 @@
+// Package formats renders parsed families in output formats other than the
+// Prometheus text format, for consumers such as InfluxDB and Graphite
+package formats
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// influxMeasurementEscaper escapes the characters special in a measurement
+var influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+
+// influxKeyEscaper escapes the characters special in tag keys, tag values
+// and field keys
+var influxKeyEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+
+// WriteInfluxDB writes every sample as an InfluxDB line protocol point with
+// nanosecond precision. Labels become tags; samples without a timestamp are
+// stamped with now. NaN and infinite values cannot be stored and are skipped.
+func WriteInfluxDB(w io.Writer, families []*openmetrics.Family, cfg config.InfluxDBFormatConfig, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		for i := range family.Samples {
+			sample := &family.Samples[i]
+			if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+				continue
+			}
+
+			name := strings.TrimPrefix(sample.Name, cfg.TrimPrefix)
+			measurement, field := name, "value"
+			if cfg.Measurement != "" {
+				measurement, field = cfg.Measurement, name
+			}
+
+			bw.WriteString(influxMeasurementEscaper.Replace(measurement))
+			for _, label := range sortedLabels(sample.Labels) {
+				// Empty tag values are not allowed by the line protocol
+				if label.Value == "" {
+					continue
+				}
+				bw.WriteByte(',')
+				bw.WriteString(influxKeyEscaper.Replace(label.Name))
+				bw.WriteByte('=')
+				bw.WriteString(influxKeyEscaper.Replace(label.Value))
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(influxKeyEscaper.Replace(field))
+			bw.WriteByte('=')
+			bw.WriteString(strconv.FormatFloat(sample.Value, 'g', -1, 64))
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.FormatInt(timestamp(sample, now).UnixNano(), 10))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// sortedLabels returns a copy of the labels sorted by name
+func sortedLabels(labels []openmetrics.Label) []openmetrics.Label {
+	sorted := append([]openmetrics.Label(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// timestamp returns the time of the sample, or now when it carries none
+func timestamp(sample *openmetrics.Sample, now time.Time) time.Time {
+	if sample.HasTimestamp {
+		return time.UnixMilli(sample.Timestamp)
+	}
+	return now
+}
@@ -0,0 +1,382 @@
+// Package hostlist expands and compresses Slurm hostlist expressions such as
+// "c[1-4,7],gpu[01-08]".
+package hostlist
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxHosts bounds the number of hosts a single expression may expand to, so
+// that a typo such as "c[1-100000000]" cannot exhaust memory
+const MaxHosts = 1 << 20
+
+// maxWidth bounds the digits of a range bound, keeping numbers within an int
+const maxWidth = 18
+
+// Expand returns every host name described by the hostlist expression
+func Expand(expr string) ([]string, error) {
+	if _, err := count(expr); err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, item := range splitTopLevel(expr) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		expanded, err := expandItem(item)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, expanded...)
+	}
+	return hosts, nil
+}
+
+// Validate checks the syntax and ranges of a hostlist expression without
+// expanding it
+func Validate(expr string) error {
+	_, err := count(expr)
+	return err
+}
+
+// Count returns the number of hosts the expression expands to
+func Count(expr string) (int, error) {
+	return count(expr)
+}
+
+// count validates the expression and returns the size of its expansion
+func count(expr string) (int, error) {
+	depth := 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '[':
+			depth++
+			if depth > 1 {
+				return 0, fmt.Errorf("nested brackets in %q", expr)
+			}
+		case ']':
+			depth--
+			if depth < 0 {
+				return 0, fmt.Errorf("unbalanced brackets in %q", expr)
+			}
+		}
+	}
+	if depth != 0 {
+		return 0, fmt.Errorf("unbalanced brackets in %q", expr)
+	}
+
+	total := 0
+	for _, item := range splitTopLevel(expr) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		size := 1
+		rest := item
+		for {
+			open := strings.IndexByte(rest, '[')
+			if open < 0 {
+				break
+			}
+			closing := strings.IndexByte(rest, ']')
+			n, err := countRanges(rest[open+1 : closing])
+			if err != nil {
+				return 0, fmt.Errorf("invalid range in %q: %w", item, err)
+			}
+			if size > MaxHosts/n {
+				return 0, fmt.Errorf("%q expands to more than %d hosts", item, MaxHosts)
+			}
+			size *= n
+			rest = rest[closing+1:]
+		}
+
+		total += size
+		if total > MaxHosts {
+			return 0, fmt.Errorf("%q expands to more than %d hosts", expr, MaxHosts)
+		}
+	}
+	return total, nil
+}
+
+// countRanges validates the content of a bracket group and returns the number
+// of values it describes
+func countRanges(ranges string) (int, error) {
+	n := 0
+	for _, part := range strings.Split(ranges, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return 0, fmt.Errorf("empty range")
+		}
+
+		lo, hi, isRange := strings.Cut(part, "-")
+		if !isDigits(lo) || (isRange && !isDigits(hi)) {
+			return 0, fmt.Errorf("%q is not numeric", part)
+		}
+		if len(lo) > maxWidth || len(hi) > maxWidth {
+			return 0, fmt.Errorf("%q has more than %d digits", part, maxWidth)
+		}
+		if !isRange {
+			n++
+			continue
+		}
+
+		start, _ := strconv.Atoi(lo)
+		end, _ := strconv.Atoi(hi)
+		if end < start {
+			return 0, fmt.Errorf("range %q is reversed", part)
+		}
+		if end-start >= MaxHosts {
+			return 0, fmt.Errorf("range %q expands to more than %d hosts", part, MaxHosts)
+		}
+		n += end - start + 1
+		if n > MaxHosts {
+			return 0, fmt.Errorf("range %q expands to more than %d hosts", ranges, MaxHosts)
+		}
+	}
+	return n, nil
+}
+
+// splitTopLevel splits on commas that are not inside brackets
+func splitTopLevel(expr string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(items, expr[start:])
+}
+
+// expandItem expands a single host pattern, possibly containing several
+// bracket groups (e.g. "rack[1-2]-node[01-04]")
+func expandItem(item string) ([]string, error) {
+	open := strings.IndexByte(item, '[')
+	if open < 0 {
+		if strings.ContainsRune(item, ']') {
+			return nil, fmt.Errorf("unbalanced brackets in %q", item)
+		}
+		return []string{item}, nil
+	}
+
+	closing := strings.IndexByte(item[open:], ']')
+	if closing < 0 {
+		return nil, fmt.Errorf("unbalanced brackets in %q", item)
+	}
+	closing += open
+
+	prefix := item[:open]
+	if strings.ContainsRune(prefix, ']') {
+		return nil, fmt.Errorf("unbalanced brackets in %q", item)
+	}
+
+	values, err := expandRanges(item[open+1 : closing])
+	if err != nil {
+		return nil, fmt.Errorf("invalid range in %q: %w", item, err)
+	}
+
+	suffixes, err := expandItem(item[closing+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]string, 0, len(values)*len(suffixes))
+	for _, value := range values {
+		for _, suffix := range suffixes {
+			hosts = append(hosts, prefix+value+suffix)
+		}
+	}
+	return hosts, nil
+}
+
+// expandRanges expands the content of a bracket group such as "01-04,7"
+func expandRanges(ranges string) ([]string, error) {
+	var values []string
+	for _, part := range strings.Split(ranges, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty range")
+		}
+
+		lo, hi, isRange := strings.Cut(part, "-")
+		if !isDigits(lo) || (isRange && !isDigits(hi)) {
+			return nil, fmt.Errorf("%q is not numeric", part)
+		}
+		if !isRange {
+			values = append(values, lo)
+			continue
+		}
+
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, err
+		}
+		end, err := strconv.Atoi(hi)
+		if err != nil {
+			return nil, err
+		}
+		if end < start {
+			return nil, fmt.Errorf("range %q is reversed", part)
+		}
+
+		// Zero padding follows the width of the lower bound
+		width := len(lo)
+		for n := start; n <= end; n++ {
+			values = append(values, fmt.Sprintf("%0*d", width, n))
+		}
+	}
+	return values, nil
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// host is a host name split into its prefix and trailing number
+type host struct {
+	prefix string
+	digits string
+	number int
+}
+
+// splitHost separates the trailing digits of a host name
+func splitHost(name string) (host, bool) {
+	i := len(name)
+	for i > 0 && name[i-1] >= '0' && name[i-1] <= '9' {
+		i--
+	}
+	if i == len(name) || len(name)-i > maxWidth {
+		return host{prefix: name}, false
+	}
+	number, err := strconv.Atoi(name[i:])
+	if err != nil {
+		return host{prefix: name}, false
+	}
+	return host{prefix: name[:i], digits: name[i:], number: number}, true
+}
+
+// run is a sequence of consecutive numbers sharing the same width
+type run struct {
+	start, end int
+	width      int
+}
+
+func (r run) String() string {
+	if r.start == r.end {
+		return fmt.Sprintf("%0*d", r.width, r.start)
+	}
+	return fmt.Sprintf("%0*d-%0*d", r.width, r.start, r.width, r.end)
+}
+
+// Compress returns the shortest hostlist expression grouping hosts that share
+// a prefix into bracketed ranges. Duplicates are removed.
+func Compress(hosts []string) string {
+	byPrefix := make(map[string][]host)
+	var plain []string
+	seen := make(map[string]bool, len(hosts))
+
+	for _, name := range hosts {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		h, ok := splitHost(name)
+		if !ok {
+			plain = append(plain, name)
+			continue
+		}
+		byPrefix[h.prefix] = append(byPrefix[h.prefix], h)
+	}
+
+	prefixes := make([]string, 0, len(byPrefix))
+	for prefix := range byPrefix {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	var parts []string
+	for _, prefix := range prefixes {
+		parts = append(parts, compressPrefix(prefix, byPrefix[prefix])...)
+	}
+	sort.Strings(plain)
+	parts = append(parts, plain...)
+
+	return strings.Join(parts, ",")
+}
+
+// paddingWidth returns the zero padding width of a number, 1 when unpadded
+func paddingWidth(digits string) int {
+	if len(digits) > 1 && digits[0] == '0' {
+		return len(digits)
+	}
+	return 1
+}
+
+// compressPrefix builds the bracketed expressions for hosts sharing a prefix
+func compressPrefix(prefix string, hosts []host) []string {
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].number != hosts[j].number {
+			return hosts[i].number < hosts[j].number
+		}
+		return hosts[i].digits < hosts[j].digits
+	})
+
+	// Build runs of consecutive numbers that format identically
+	var runs []run
+	for _, h := range hosts {
+		if n := len(runs); n > 0 {
+			last := &runs[n-1]
+			if h.number == last.end+1 && fmt.Sprintf("%0*d", last.width, h.number) == h.digits {
+				last.end = h.number
+				continue
+			}
+		}
+		runs = append(runs, run{start: h.number, end: h.number, width: paddingWidth(h.digits)})
+	}
+
+	// Runs of different widths cannot share a bracket group without changing
+	// the padding, so they are grouped by width
+	byWidth := make(map[int][]string)
+	var widths []int
+	for _, r := range runs {
+		if _, ok := byWidth[r.width]; !ok {
+			widths = append(widths, r.width)
+		}
+		byWidth[r.width] = append(byWidth[r.width], r.String())
+	}
+
+	parts := make([]string, 0, len(widths))
+	for _, width := range widths {
+		ranges := byWidth[width]
+		if len(ranges) == 1 && !strings.Contains(ranges[0], "-") {
+			parts = append(parts, prefix+ranges[0])
+			continue
+		}
+		parts = append(parts, prefix+"["+strings.Join(ranges, ",")+"]")
+	}
+	return parts
+}
@@ -0,0 +1,171 @@
+package hostlist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"c1", []string{"c1"}},
+		{"c[1-4,7]", []string{"c1", "c2", "c3", "c4", "c7"}},
+		{"gpu[01-03],login1", []string{"gpu01", "gpu02", "gpu03", "login1"}},
+		{"c[098-101]", []string{"c098", "c099", "c100", "c101"}},
+		{"rack[1-2]-n[1-2]", []string{"rack1-n1", "rack1-n2", "rack2-n1", "rack2-n2"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := Expand(tt.expr)
+		if err != nil {
+			t.Errorf("Expand(%q) returned an error: %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Expand(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestExpandInvalid(t *testing.T) {
+	for _, expr := range []string{"c[1-4", "c1-4]", "c[4-1]", "c[a-b]", "c[1,,2]", "c[]"} {
+		if _, err := Expand(expr); err == nil {
+			t.Errorf("Expected an error expanding %q", expr)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	tests := []struct {
+		hosts []string
+		want  string
+	}{
+		{[]string{"c1", "c2", "c3", "c4", "c7"}, "c[1-4,7]"},
+		{[]string{"gpu02", "gpu01", "gpu03", "c1"}, "c1,gpu[01-03]"},
+		{[]string{"c09", "c10", "c11"}, "c[09-11]"},
+		{[]string{"c9", "c10"}, "c[9-10]"},
+		{[]string{"login", "c1", "c1"}, "c1,login"},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := Compress(tt.hosts); got != tt.want {
+			t.Errorf("Compress(%v) = %q, want %q", tt.hosts, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		expr  string
+		valid bool
+	}{
+		{"c[1-4,7],gpu[01-08]", true},
+		{"rack[1-2]-n[01-32]", true},
+		{"c[1-[2]]", false},
+		{"c[0-99999999999]", false},
+		{"c[1-1048576]", true},
+		{"c[0-1048576]", false},
+		{"c[1-1024]-n[1-2048]", false},
+		{"c[1234567890123456789]", false},
+	}
+
+	for _, tt := range tests {
+		err := Validate(tt.expr)
+		if tt.valid && err != nil {
+			t.Errorf("Validate(%q) returned an error: %v", tt.expr, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("Expected Validate(%q) to fail", tt.expr)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	set, err := NewSet("c[1-4]", "gpu[01-02]", "c3")
+	if err != nil {
+		t.Fatalf("Failed to create set: %v", err)
+	}
+
+	if set.Len() != 6 {
+		t.Errorf("Expected 6 hosts, got %d", set.Len())
+	}
+	if !set.Contains("gpu02") || set.Contains("gpu2") || set.Contains("c5") {
+		t.Error("Unexpected set membership")
+	}
+	if got := set.String(); got != "c[1-4],gpu[01-02]" {
+		t.Errorf("Unexpected set expression %q", got)
+	}
+}
+
+func FuzzExpand(f *testing.F) {
+	for _, seed := range []string{"c[1-4,7],gpu[01-08]", "rack[1-2]-n[01-04]", "c9,c10", "login", "c[1-", "a[0-0]b[9]"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, expr string) {
+		hosts, err := Expand(expr)
+		if err != nil {
+			return
+		}
+
+		n, err := Count(expr)
+		if err != nil || n != len(hosts) {
+			t.Fatalf("Count(%q) = %d, %v; expanded to %d hosts", expr, n, err, len(hosts))
+		}
+
+		compressed := Compress(hosts)
+		again, err := Expand(compressed)
+		if err != nil {
+			t.Fatalf("Expand(Compress(%q)) = Expand(%q) failed: %v", expr, compressed, err)
+		}
+		if !sameHosts(hosts, again) {
+			t.Fatalf("Round trip of %q through %q changed the hosts: %v != %v", expr, compressed, hosts, again)
+		}
+	})
+}
+
+func FuzzCompress(f *testing.F) {
+	f.Add("c1 c2 c3 c10 gpu01 gpu02 login")
+	f.Add("n001 n002 n010 n1 n2")
+
+	f.Fuzz(func(t *testing.T, names string) {
+		var hosts []string
+		for _, name := range strings.Fields(names) {
+			// Hostlist syntax characters cannot appear in host names
+			if strings.ContainsAny(name, "[],") {
+				return
+			}
+			hosts = append(hosts, name)
+		}
+
+		compressed := Compress(hosts)
+		expanded, err := Expand(compressed)
+		if err != nil {
+			t.Fatalf("Expand(%q) failed: %v", compressed, err)
+		}
+		if !sameHosts(hosts, expanded) {
+			t.Fatalf("Round trip through %q changed the hosts: %v != %v", compressed, hosts, expanded)
+		}
+	})
+}
+
+// sameHosts compares two host lists ignoring order and duplicates
+func sameHosts(a, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, host := range a {
+		set[host] = true
+	}
+	other := make(map[string]bool, len(b))
+	for _, host := range b {
+		if !set[host] {
+			return false
+		}
+		other[host] = true
+	}
+	return len(set) == len(other)
+}
@@ -0,0 +1,41 @@
+package hostlist
+
+// Set is a collection of host names built from hostlist expressions
+type Set struct {
+	hosts map[string]struct{}
+}
+
+// NewSet expands every expression into a single set
+func NewSet(exprs ...string) (*Set, error) {
+	s := &Set{hosts: make(map[string]struct{})}
+	for _, expr := range exprs {
+		hosts, err := Expand(expr)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			s.hosts[host] = struct{}{}
+		}
+	}
+	return s, nil
+}
+
+// Contains reports whether host is part of the set
+func (s *Set) Contains(host string) bool {
+	_, ok := s.hosts[host]
+	return ok
+}
+
+// Len returns the number of hosts in the set
+func (s *Set) Len() int {
+	return len(s.hosts)
+}
+
+// String returns the set as a compressed hostlist expression
+func (s *Set) String() string {
+	hosts := make([]string, 0, len(s.hosts))
+	for host := range s.hosts {
+		hosts = append(hosts, host)
+	}
+	return Compress(hosts)
+}
@@ -0,0 +1,131 @@
+package logsink
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// rotateRetryInterval is how long writes keep going to the current file
+// after a failed rotation before it is tried again
+const rotateRetryInterval = time.Minute
+
+// RotatingFile is a log file rotated by size: once a write would exceed the
+// maximum size, path is renamed to path.1, path.1 to path.2 and so on, and
+// the oldest backup beyond maxBackups is removed
+type RotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	// openFile opens the log file, replaced in tests
+	openFile func(name string, flag int, perm os.FileMode) (*os.File, error)
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	retryAt time.Time
+}
+
+// OpenRotatingFile opens or creates the log file, appending to it
+func OpenRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups, openFile: os.OpenFile}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the current file and records its size
+func (f *RotatingFile) open() error {
+	file, err := f.openFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating it first when p would not fit. A
+// single write larger than the maximum size still goes to a fresh file. When
+// the rotation fails, p still goes to the current file, the rotation error is
+// returned and the rotation is not tried again for rotateRetryInterval.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var rotateErr error
+	now := time.Now()
+	if f.size > 0 && f.size+int64(len(p)) > f.maxBytes && !now.Before(f.retryAt) {
+		if rotateErr = f.rotate(); rotateErr != nil {
+			f.retryAt = now.Add(rotateRetryInterval)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, errors.Join(rotateErr, err)
+}
+
+// rotate starts a new file and shifts the backups. The current file is
+// moved aside while still open and the backups are only shifted once the new
+// file is open; when it cannot be opened, the current file is moved back, so
+// that a failed rotation neither loses records nor removes a backup.
+func (f *RotatingFile) rotate() error {
+	rotating := f.path + ".rotating"
+
+	// Finish a rotation that failed after the new file was opened
+	if info, err := os.Lstat(rotating); err == nil && info.Mode().IsRegular() {
+		if err := f.shift(rotating); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(f.path, rotating); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	current := f.file
+	if err := f.open(); err != nil {
+		if restoreErr := os.Rename(rotating, f.path); restoreErr != nil && !os.IsNotExist(restoreErr) {
+			return errors.Join(err, fmt.Errorf("failed to restore log file: %w", restoreErr))
+		}
+		return err
+	}
+	current.Close()
+
+	return f.shift(rotating)
+}
+
+// shift removes the oldest backup, renames the others and makes the rotated
+// file the first backup
+func (f *RotatingFile) shift(rotated string) error {
+	os.Remove(f.backup(f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		os.Rename(f.backup(i), f.backup(i+1))
+	}
+	if err := os.Rename(rotated, f.backup(1)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return nil
+}
+
+// backup returns the path of the given backup, starting at 1
+func (f *RotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
+
+// Close closes the file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,88 @@
+package logsink
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// SeverityWriter is a sink that records a severity with every message, such
+// as syslog or journald
+type SeverityWriter interface {
+	WriteSeverity(severity int, p []byte) (int, error)
+}
+
+// NewHandler returns a slog handler writing text or JSON records to w. When
+// w is a SeverityWriter, every record is sent with the severity matching its
+// level.
+func NewHandler(w io.Writer, format string, opts *slog.HandlerOptions) slog.Handler {
+	sink, ok := w.(SeverityWriter)
+	if !ok {
+		return newFormatHandler(w, format, opts)
+	}
+
+	writer := &severityWriter{sink: sink}
+	return &severityHandler{inner: newFormatHandler(writer, format, opts), writer: writer}
+}
+
+// newFormatHandler returns the standard handler of the format
+func newFormatHandler(w io.Writer, format string, opts *slog.HandlerOptions) slog.Handler {
+	if format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// severityWriter forwards the writes of the standard handlers with the
+// severity of the record being handled
+type severityWriter struct {
+	sink SeverityWriter
+
+	mu       sync.Mutex
+	severity int
+}
+
+func (w *severityWriter) Write(p []byte) (int, error) {
+	return w.sink.WriteSeverity(w.severity, p)
+}
+
+// severityHandler sets the severity of the writer around every record. The
+// standard handlers write a record in a single call, so holding the lock for
+// the whole record is enough.
+type severityHandler struct {
+	inner  slog.Handler
+	writer *severityWriter
+}
+
+func (h *severityHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *severityHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.writer.mu.Lock()
+	defer h.writer.mu.Unlock()
+	h.writer.severity = severity(record.Level)
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *severityHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &severityHandler{inner: h.inner.WithAttrs(attrs), writer: h.writer}
+}
+
+func (h *severityHandler) WithGroup(name string) slog.Handler {
+	return &severityHandler{inner: h.inner.WithGroup(name), writer: h.writer}
+}
+
+// severity maps a slog level to a syslog severity
+func severity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return SeverityError
+	case level >= slog.LevelWarn:
+		return SeverityWarning
+	case level >= slog.LevelInfo:
+		return SeverityInfo
+	}
+	return SeverityDebug
+}
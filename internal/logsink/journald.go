@@ -0,0 +1,99 @@
+package logsink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Journald sends entries to the systemd journal over its native protocol,
+// one entry per write. Entries must fit in a single datagram; the memfd
+// fallback for larger entries is not implemented.
+type Journald struct {
+	socket     string
+	identifier string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// DialJournald connects to the journal socket, usually
+// /run/systemd/journal/socket
+func DialJournald(socket, identifier string) (*Journald, error) {
+	j := &Journald{socket: socket, identifier: identifier}
+	if err := j.connect(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// connect opens the datagram socket
+func (j *Journald) connect() error {
+	conn, err := net.Dial("unixgram", j.socket)
+	if err != nil {
+		return fmt.Errorf("failed to connect to journald socket %s: %w", j.socket, err)
+	}
+	j.conn = conn
+	return nil
+}
+
+// Write sends p as an informational entry
+func (j *Journald) Write(p []byte) (int, error) {
+	return j.WriteSeverity(SeverityInfo, p)
+}
+
+// WriteSeverity sends p as an entry of the given priority. Trailing newlines
+// are removed. The connection is reopened once when journald was restarted.
+func (j *Journald) WriteSeverity(severity int, p []byte) (int, error) {
+	entry := j.format(severity, bytes.TrimRight(p, "\n"))
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.conn.Write(entry); err != nil {
+		j.conn.Close()
+		if err := j.connect(); err != nil {
+			return 0, err
+		}
+		if _, err := j.conn.Write(entry); err != nil {
+			return 0, fmt.Errorf("failed to write to journald: %w", err)
+		}
+	}
+	return len(p), nil
+}
+
+// format builds the entry as KEY=value lines. Values containing a newline
+// use the binary form: the key, a newline, the little-endian 64-bit length
+// of the value, the value and a newline.
+func (j *Journald) format(severity int, msg []byte) []byte {
+	var entry bytes.Buffer
+	writeJournalField(&entry, "PRIORITY", []byte(strconv.Itoa(severity)))
+	writeJournalField(&entry, "SYSLOG_IDENTIFIER", []byte(j.identifier))
+	writeJournalField(&entry, "MESSAGE", msg)
+	return entry.Bytes()
+}
+
+// writeJournalField appends one field to an entry
+func writeJournalField(entry *bytes.Buffer, key string, value []byte) {
+	entry.WriteString(key)
+	if bytes.IndexByte(value, '\n') < 0 {
+		entry.WriteByte('=')
+		entry.Write(value)
+		entry.WriteByte('\n')
+		return
+	}
+	entry.WriteByte('\n')
+	binary.Write(entry, binary.LittleEndian, uint64(len(value)))
+	entry.Write(value)
+	entry.WriteByte('\n')
+}
+
+// Close closes the connection to journald
+func (j *Journald) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.conn.Close()
+}
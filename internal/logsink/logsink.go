@@ -0,0 +1,39 @@
+// Package logsink opens the destinations of log streams: standard output,
+// size-rotated files, the local syslog daemon and the systemd journal
+package logsink
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+// nopCloser wraps the standard streams, which must stay open
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// Open opens the sink selected by the configuration
+func Open(cfg config.LogSinkConfig) (io.WriteCloser, error) {
+	switch cfg.Output {
+	case "", "stdout":
+		return nopCloser{os.Stdout}, nil
+	case "stderr":
+		return nopCloser{os.Stderr}, nil
+	case "file":
+		return OpenRotatingFile(cfg.File.Path, int64(cfg.File.MaxSizeMB)<<20, cfg.File.MaxBackups)
+	case "syslog":
+		facility, ok := config.SyslogFacilities[cfg.Syslog.Facility]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog facility %q", cfg.Syslog.Facility)
+		}
+		return DialSyslog(cfg.Syslog.Socket, facility, cfg.Syslog.Tag)
+	case "journald":
+		return DialJournald(cfg.Journald.Socket, cfg.Journald.Identifier)
+	}
+	return nil, fmt.Errorf("unsupported log output %q", cfg.Output)
+}
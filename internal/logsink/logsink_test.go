@@ -0,0 +1,331 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: expected %q, got %q", filepath.Base(file), content, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected at most 2 backups")
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	os.WriteFile(path, []byte("existing\n"), 0o640)
+
+	f, err := OpenRotatingFile(path, 12, 1)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	f.Write([]byte("new\n"))
+	f.Close()
+
+	// The existing 9 bytes count towards the limit
+	if data, _ := os.ReadFile(path); string(data) != "new\n" {
+		t.Errorf("Expected the file to be rotated, got %q", data)
+	}
+	if data, _ := os.ReadFile(path + ".1"); string(data) != "existing\n" {
+		t.Errorf("Expected the previous content in the backup, got %q", data)
+	}
+}
+
+// checkFiles verifies the content of the log file and its backups
+func checkFiles(t *testing.T, want map[string]string) {
+	t.Helper()
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s: expected %q, got %q", filepath.Base(file), content, data)
+		}
+	}
+}
+
+func TestRotatingFileReadOnlyDirectory(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Directory permissions do not apply to root")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	f, err := OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	defer f.Close()
+	f.Write([]byte("first\n"))
+
+	os.Chmod(dir, 0o500)
+	defer os.Chmod(dir, 0o700)
+	if n, err := f.Write([]byte("second\n")); err == nil || n != 7 {
+		t.Fatalf("Expected the record to be written despite the failed rotation, got %d and %v", n, err)
+	}
+
+	os.Chmod(dir, 0o700)
+	f.retryAt = time.Time{}
+	if _, err := f.Write([]byte("third\n")); err != nil {
+		t.Fatalf("Expected the rotation to succeed once the directory is writable, got %v", err)
+	}
+	checkFiles(t, map[string]string{path: "third\n", path + ".1": "first\nsecond\n"})
+}
+
+func TestRotatingFileFailedRename(t *testing.T) {
+	// A non-empty directory in the way makes the rename fail, even for root
+	path := filepath.Join(t.TempDir(), "access.log")
+	os.MkdirAll(filepath.Join(path+".rotating", "blocker"), 0o700)
+
+	f, err := OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	defer f.Close()
+	f.Write([]byte("first\n"))
+
+	if n, err := f.Write([]byte("second\n")); err == nil || n != 7 {
+		t.Fatalf("Expected the record to be written despite the failed rotation, got %d and %v", n, err)
+	}
+
+	// The rotation is not retried on every write
+	os.RemoveAll(path + ".rotating")
+	if _, err := f.Write([]byte("third\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	checkFiles(t, map[string]string{path: "first\nsecond\nthird\n"})
+
+	f.retryAt = time.Time{}
+	if _, err := f.Write([]byte("fourth\n")); err != nil {
+		t.Fatalf("Expected the rotation to succeed once retried, got %v", err)
+	}
+	checkFiles(t, map[string]string{path: "fourth\n", path + ".1": "first\nsecond\nthird\n"})
+}
+
+func TestRotatingFileFailedOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	os.WriteFile(path+".1", []byte("backup\n"), 0o640)
+
+	f, err := OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	defer f.Close()
+	f.Write([]byte("first\n"))
+
+	// The current file is renamed, but the new one cannot be opened
+	f.openFile = func(string, int, os.FileMode) (*os.File, error) {
+		return nil, errors.New("too many open files")
+	}
+	if n, err := f.Write([]byte("second\n")); err == nil || n != 7 {
+		t.Fatalf("Expected the record to be written despite the failed rotation, got %d and %v", n, err)
+	}
+	f.retryAt = time.Time{}
+	if _, err := f.Write([]byte("third\n")); err == nil {
+		t.Fatalf("Expected the second rotation to fail too")
+	}
+	checkFiles(t, map[string]string{path: "first\nsecond\nthird\n", path + ".1": "backup\n"})
+
+	f.openFile = os.OpenFile
+	f.retryAt = time.Time{}
+	if _, err := f.Write([]byte("fourth\n")); err != nil {
+		t.Fatalf("Expected the rotation to succeed once the file can be opened, got %v", err)
+	}
+	checkFiles(t, map[string]string{path: "fourth\n", path + ".1": "first\nsecond\nthird\n"})
+}
+
+func TestRotatingFileUnfinishedRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	os.WriteFile(path+".rotating", []byte("rotated\n"), 0o640)
+
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	defer f.Close()
+	f.Write([]byte("first\n"))
+	if _, err := f.Write([]byte("second\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	checkFiles(t, map[string]string{path: "second\n", path + ".1": "first\n", path + ".2": "rotated\n"})
+	if _, err := os.Stat(path + ".rotating"); !os.IsNotExist(err) {
+		t.Errorf("Expected the unfinished rotation to be completed")
+	}
+}
+
+func TestSyslogDialErrors(t *testing.T) {
+	_, err := DialSyslog(filepath.Join(t.TempDir(), "missing"), 16, "slurm_exporter")
+	if err == nil || strings.Count(err.Error(), "no such file or directory") != 2 {
+		t.Errorf("Expected the datagram and stream errors, got %v", err)
+	}
+}
+
+func TestSyslog(t *testing.T) {
+	// Unix socket paths are limited to about 100 bytes
+	dir, err := os.MkdirTemp("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "log")
+
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("Unix datagram sockets are not available: %v", err)
+	}
+	defer listener.Close()
+
+	s, err := DialSyslog(socket, 16, "slurm_exporter")
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.WriteSeverity(SeverityWarning, []byte(`{"msg":"hello"}`+"\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	buffer := make([]byte, 1024)
+	listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := listener.Read(buffer)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	// local0 (16) * 8 + warning (4) = 132
+	pattern := regexp.MustCompile(`^<132>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(Z|[+-]\d\d:\d\d) \S+ slurm_exporter \d+ - - \{"msg":"hello"\}$`)
+	if message := string(buffer[:n]); !pattern.MatchString(message) || strings.HasSuffix(message, "\n") {
+		t.Errorf("Unexpected RFC 5424 message %q", message)
+	}
+}
+
+func TestJournald(t *testing.T) {
+	dir, err := os.MkdirTemp("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "socket")
+
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("Unix datagram sockets are not available: %v", err)
+	}
+	defer listener.Close()
+
+	j, err := DialJournald(socket, "slurm_exporter")
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer j.Close()
+
+	buffer := make([]byte, 1024)
+	read := func() string {
+		listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, err := listener.Read(buffer)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return string(buffer[:n])
+	}
+
+	j.WriteSeverity(SeverityError, []byte("failed\n"))
+	if entry, want := read(), "PRIORITY=3\nSYSLOG_IDENTIFIER=slurm_exporter\nMESSAGE=failed\n"; entry != want {
+		t.Errorf("Expected %q, got %q", want, entry)
+	}
+
+	// Multi-line messages use the length-prefixed form
+	j.Write([]byte("line 1\nline 2"))
+	var length bytes.Buffer
+	binary.Write(&length, binary.LittleEndian, uint64(13))
+	want := "PRIORITY=6\nSYSLOG_IDENTIFIER=slurm_exporter\nMESSAGE\n" + length.String() + "line 1\nline 2\n"
+	if entry := read(); entry != want {
+		t.Errorf("Expected %q, got %q", want, entry)
+	}
+}
+
+// severityRecorder records the severities of the messages written to it
+type severityRecorder struct {
+	severities []int
+	messages   []string
+}
+
+func (r *severityRecorder) Write(p []byte) (int, error) {
+	return r.WriteSeverity(SeverityInfo, p)
+}
+
+func (r *severityRecorder) WriteSeverity(severity int, p []byte) (int, error) {
+	r.severities = append(r.severities, severity)
+	r.messages = append(r.messages, string(p))
+	return len(p), nil
+}
+
+func TestHandlerSeverity(t *testing.T) {
+	recorder := &severityRecorder{}
+	logger := slog.New(NewHandler(recorder, "json", &slog.HandlerOptions{Level: slog.LevelDebug})).
+		With("component", "test")
+
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Log(context.Background(), slog.LevelError+4, "fatal")
+
+	want := []int{SeverityDebug, SeverityInfo, SeverityWarning, SeverityError}
+	if len(recorder.severities) != len(want) {
+		t.Fatalf("Expected %d messages, got %d", len(want), len(recorder.severities))
+	}
+	for i, severity := range want {
+		if recorder.severities[i] != severity {
+			t.Errorf("Message %d: expected severity %d, got %d", i, severity, recorder.severities[i])
+		}
+	}
+	if !strings.Contains(recorder.messages[0], `"component":"test"`) {
+		t.Errorf("Expected the attributes in the message, got %q", recorder.messages[0])
+	}
+}
+
+func TestHandlerPlainWriter(t *testing.T) {
+	var output bytes.Buffer
+	slog.New(NewHandler(&output, "text", nil)).Info("hello")
+	if !strings.Contains(output.String(), "level=INFO msg=hello") {
+		t.Errorf("Expected a text record, got %q", output.String())
+	}
+}
@@ -0,0 +1,116 @@
+package logsink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Syslog severities of RFC 5424
+const (
+	SeverityError   = 3
+	SeverityWarning = 4
+	SeverityInfo    = 6
+	SeverityDebug   = 7
+)
+
+// Syslog sends RFC 5424 messages to the local syslog daemon, one message per
+// write. Datagram sockets are preferred; on stream sockets every message is
+// terminated by a newline.
+type Syslog struct {
+	socket   string
+	facility int
+	tag      string
+	hostname string
+	pid      int
+
+	mu     sync.Mutex
+	conn   net.Conn
+	stream bool
+}
+
+// DialSyslog connects to the syslog socket, such as /dev/log
+func DialSyslog(socket string, facility int, tag string) (*Syslog, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	s := &Syslog{socket: socket, facility: facility, tag: tag, hostname: hostname, pid: os.Getpid()}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// connect opens the socket, trying a datagram socket first
+func (s *Syslog) connect() error {
+	conn, err := net.Dial("unixgram", s.socket)
+	if err == nil {
+		s.conn, s.stream = conn, false
+		return nil
+	}
+	conn, streamErr := net.Dial("unix", s.socket)
+	if streamErr != nil {
+		return fmt.Errorf("failed to connect to syslog socket %s: %w", s.socket, errors.Join(err, streamErr))
+	}
+	s.conn, s.stream = conn, true
+	return nil
+}
+
+// Write sends p as an informational message
+func (s *Syslog) Write(p []byte) (int, error) {
+	return s.WriteSeverity(SeverityInfo, p)
+}
+
+// WriteSeverity sends p as a message of the given severity. Trailing
+// newlines are removed. The connection is reopened once when the daemon was
+// restarted.
+func (s *Syslog) WriteSeverity(severity int, p []byte) (int, error) {
+	message := s.format(severity, bytes.TrimRight(p, "\n"), time.Now())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.send(message); err != nil {
+		s.conn.Close()
+		if err := s.connect(); err != nil {
+			return 0, err
+		}
+		if _, err := s.send(message); err != nil {
+			return 0, fmt.Errorf("failed to write to syslog: %w", err)
+		}
+	}
+	return len(p), nil
+}
+
+// send writes a formatted message to the connection
+func (s *Syslog) send(message []byte) (int, error) {
+	if s.stream {
+		message = append(message, '\n')
+	}
+	return s.conn.Write(message)
+}
+
+// format builds the RFC 5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (s *Syslog) format(severity int, msg []byte, now time.Time) []byte {
+	header := fmt.Sprintf("<%d>1 %s %s %s %d - - ",
+		s.facility*8+severity,
+		now.Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname,
+		s.tag,
+		s.pid)
+	return append([]byte(header), msg...)
+}
+
+// Close closes the connection to the syslog daemon
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.Close()
+}
@@ -14,10 +14,28 @@ type Registry struct {
 	ScrapeDuration *prometheus.HistogramVec
 	ScrapeSuccess  *prometheus.GaugeVec
 	ScrapeErrors   *prometheus.CounterVec
+	SeriesCount    *prometheus.GaugeVec
+	SeriesDropped  *prometheus.CounterVec
+
+	// Upstream resilience metrics
+	UpstreamRetries          *prometheus.CounterVec
+	CircuitBreakerState      *prometheus.GaugeVec
+	ActiveUpstream           *prometheus.GaugeVec
+	EndpointStale            *prometheus.GaugeVec
+	UpstreamTransferredBytes *prometheus.CounterVec
+	UpstreamDecodedBytes     *prometheus.CounterVec
+	CoalescedRequests        *prometheus.CounterVec
+	RemoteWriteSamples       prometheus.Counter
+	RemoteWriteFailures      prometheus.Counter
+	RemoteWriteDropped       prometheus.Counter
+	RemoteWriteQueueLength   prometheus.Gauge
+	OTLPExportedPoints       prometheus.Counter
+	OTLPFailedExports        prometheus.Counter
 
 	// HTTP metrics
-	HTTPRequestsTotal   *prometheus.CounterVec
-	HTTPRequestDuration *prometheus.HistogramVec
+	HTTPRequestsTotal    *prometheus.CounterVec
+	HTTPRequestDuration  *prometheus.HistogramVec
+	HTTPRejectedRequests *prometheus.CounterVec
 
 	// Custom registry for Slurm metrics
 	customRegistry *prometheus.Registry
@@ -69,6 +87,127 @@ func NewRegistry(version, gitCommit, buildTime string, debugMode bool) *Registry
 		[]string{"endpoint"},
 	)
 
+	// Series count gauge
+	reg.SeriesCount = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "slurm_exporter_series_count",
+			Help: "Number of series exported for the endpoint in the last scrape",
+		},
+		[]string{"endpoint"},
+	)
+
+	// Series dropped counter
+	reg.SeriesDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "slurm_exporter_series_dropped_total",
+			Help: "Total number of series aggregated into the __other__ label value by cardinality limits",
+		},
+		[]string{"endpoint"},
+	)
+
+	// Upstream retries counter
+	reg.UpstreamRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "slurm_exporter_upstream_retries_total",
+			Help: "Total number of retried upstream requests by endpoint",
+		},
+		[]string{"endpoint"},
+	)
+
+	// Circuit breaker state gauge
+	reg.CircuitBreakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "slurm_exporter_circuit_breaker_state",
+			Help: "State of the upstream circuit breaker (0 = closed, 1 = open, 2 = half-open)",
+		},
+		[]string{"endpoint"},
+	)
+
+	// Active upstream gauge
+	reg.ActiveUpstream = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "slurm_exporter_active_upstream",
+			Help: "Whether the Slurm upstream URL is the one currently in use (1 = active, 0 = standby)",
+		},
+		[]string{"url"},
+	)
+
+	// Endpoint stale gauge
+	reg.EndpointStale = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "slurm_exporter_endpoint_stale",
+			Help: "Whether the endpoint is served from the last known good payload (1 = stale, 0 = fresh)",
+		},
+		[]string{"endpoint"},
+	)
+
+	// Upstream transferred bytes counter
+	reg.UpstreamTransferredBytes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "slurm_exporter_upstream_transferred_bytes_total",
+			Help: "Total number of bytes received from the Slurm API, before decompression",
+		},
+		[]string{"endpoint"},
+	)
+
+	// Upstream decoded bytes counter
+	reg.UpstreamDecodedBytes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "slurm_exporter_upstream_decoded_bytes_total",
+			Help: "Total number of bytes received from the Slurm API, after decompression",
+		},
+		[]string{"endpoint"},
+	)
+
+	// Coalesced requests counter
+	reg.CoalescedRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "slurm_exporter_coalesced_requests_total",
+			Help: "Total number of scrapes that shared an in-flight collection of the endpoint",
+		},
+		[]string{"endpoint"},
+	)
+
+	// Remote write metrics
+	reg.RemoteWriteSamples = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "slurm_exporter_remote_write_samples_total",
+			Help: "Total number of samples pushed to the remote write receiver",
+		},
+	)
+	reg.RemoteWriteFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "slurm_exporter_remote_write_failed_batches_total",
+			Help: "Total number of batches that could not be pushed after all retries",
+		},
+	)
+	reg.RemoteWriteDropped = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "slurm_exporter_remote_write_dropped_batches_total",
+			Help: "Total number of batches dropped because the remote write queue was full",
+		},
+	)
+	reg.RemoteWriteQueueLength = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "slurm_exporter_remote_write_queue_length",
+			Help: "Number of batches waiting to be pushed to the remote write receiver",
+		},
+	)
+
+	// OTLP metrics
+	reg.OTLPExportedPoints = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "slurm_exporter_otlp_exported_points_total",
+			Help: "Total number of data points exported to the OTLP collector",
+		},
+	)
+	reg.OTLPFailedExports = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "slurm_exporter_otlp_failed_exports_total",
+			Help: "Total number of OTLP exports that failed",
+		},
+	)
+
 	// HTTP requests total counter
 	reg.HTTPRequestsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -88,6 +227,15 @@ func NewRegistry(version, gitCommit, buildTime string, debugMode bool) *Registry
 		[]string{"method", "path"},
 	)
 
+	// HTTP requests rejected by the rate limit or the scrape concurrency limit
+	reg.HTTPRejectedRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "slurm_exporter_http_rejected_requests_total",
+			Help: "Total number of HTTP requests rejected by the request limits, by reason",
+		},
+		[]string{"reason"},
+	)
+
 	return reg
 }
 
@@ -0,0 +1,504 @@
+package openmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metric types as they appear in the "# TYPE" comment
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+	TypeSummary   = "summary"
+	TypeUntyped   = "untyped"
+)
+
+// Label is a single label name/value pair
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single series value within a family
+type Sample struct {
+	Name         string
+	Labels       []Label
+	Value        float64
+	Timestamp    int64 // milliseconds since epoch, only valid when HasTimestamp is set
+	HasTimestamp bool
+}
+
+// Family groups the samples sharing the same HELP and TYPE metadata
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Unit    string
+	Samples []Sample
+}
+
+// sampleSuffixes are the suffixes a sample name may carry relative to its family name
+var sampleSuffixes = []string{"_total", "_bucket", "_sum", "_count", "_created", "_info"}
+
+// Label returns the value of the named label and whether it was present
+func (s *Sample) Label(name string) (string, bool) {
+	for _, l := range s.Labels {
+		if l.Name == name {
+			return l.Value, true
+		}
+	}
+	return "", false
+}
+
+// SetLabel sets the named label, replacing an existing value if present
+func (s *Sample) SetLabel(name, value string) {
+	for i := range s.Labels {
+		if s.Labels[i].Name == name {
+			s.Labels[i].Value = value
+			return
+		}
+	}
+	s.Labels = append(s.Labels, Label{Name: name, Value: value})
+}
+
+// Key returns a string uniquely identifying the series (name and sorted labels)
+func (s *Sample) Key() string {
+	labels := make([]Label, len(s.Labels))
+	copy(labels, s.Labels)
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+
+	var b strings.Builder
+	b.WriteString(s.Name)
+	for _, l := range labels {
+		b.WriteByte(0xff)
+		b.WriteString(l.Name)
+		b.WriteByte('=')
+		b.WriteString(l.Value)
+	}
+	return b.String()
+}
+
+// Clone returns a deep copy of the sample
+func (s Sample) Clone() Sample {
+	labels := make([]Label, len(s.Labels))
+	copy(labels, s.Labels)
+	s.Labels = labels
+	return s
+}
+
+// Clone returns a deep copy of the family
+func (f *Family) Clone() *Family {
+	clone := *f
+	clone.Samples = make([]Sample, len(f.Samples))
+	for i, s := range f.Samples {
+		clone.Samples[i] = s.Clone()
+	}
+	return &clone
+}
+
+// Rename renames the family and every sample carrying the family name
+func (f *Family) Rename(name string) {
+	for i := range f.Samples {
+		if strings.HasPrefix(f.Samples[i].Name, f.Name) {
+			f.Samples[i].Name = name + strings.TrimPrefix(f.Samples[i].Name, f.Name)
+		}
+	}
+	f.Name = name
+}
+
+// belongsTo reports whether a sample name is part of the given family
+func belongsTo(sampleName string, family *Family) bool {
+	if sampleName == family.Name {
+		return true
+	}
+	if !strings.HasPrefix(sampleName, family.Name) {
+		return false
+	}
+	suffix := sampleName[len(family.Name):]
+	for _, s := range sampleSuffixes {
+		if suffix == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Decoder reads metric families one at a time from a text exposition stream
+type Decoder struct {
+	scanner *bufio.Scanner
+	pending *Family
+	line    int
+	done    bool
+}
+
+// NewDecoder creates a decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &Decoder{scanner: scanner}
+}
+
+// Next returns the next complete family, or io.EOF when the stream is exhausted
+func (d *Decoder) Next() (*Family, error) {
+	for !d.done && d.scanner.Scan() {
+		d.line++
+		line := strings.TrimSpace(d.scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			done, family, err := d.parseComment(line)
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				d.done = true
+				break
+			}
+			if family != nil {
+				return family, nil
+			}
+			continue
+		}
+
+		sample, err := parseSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", d.line, err)
+		}
+
+		if d.pending != nil && belongsTo(sample.Name, d.pending) {
+			d.pending.Samples = append(d.pending.Samples, sample)
+			continue
+		}
+
+		// A sample without preceding metadata starts a new untyped family
+		previous := d.pending
+		d.pending = &Family{Name: sample.Name, Type: TypeUntyped, Samples: []Sample{sample}}
+		if previous != nil {
+			return previous, nil
+		}
+	}
+
+	if err := d.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if d.pending != nil {
+		family := d.pending
+		d.pending = nil
+		return family, nil
+	}
+
+	return nil, io.EOF
+}
+
+// parseComment handles HELP, TYPE, UNIT and EOF comments. It returns the
+// previously pending family when the comment starts a new one.
+func (d *Decoder) parseComment(line string) (bool, *Family, error) {
+	fields := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(line, "#")), " ", 3)
+	if len(fields) == 1 && fields[0] == "EOF" {
+		return true, nil, nil
+	}
+	if len(fields) < 2 {
+		return false, nil, nil
+	}
+
+	keyword, name := fields[0], fields[1]
+	text := ""
+	if len(fields) == 3 {
+		text = fields[2]
+	}
+
+	switch keyword {
+	case "HELP", "TYPE", "UNIT":
+	default:
+		// Other comments carry no metadata
+		return false, nil, nil
+	}
+
+	var previous *Family
+	if d.pending == nil || d.pending.Name != name {
+		previous = d.pending
+		d.pending = &Family{Name: name, Type: TypeUntyped}
+	}
+
+	switch keyword {
+	case "HELP":
+		d.pending.Help = unescapeHelp(text)
+	case "TYPE":
+		if text == "" {
+			return false, nil, fmt.Errorf("line %d: missing type for %s", d.line, name)
+		}
+		if text == "unknown" {
+			text = TypeUntyped
+		}
+		d.pending.Type = text
+	case "UNIT":
+		d.pending.Unit = text
+	}
+
+	return false, previous, nil
+}
+
+// parseSample parses a line of the form name{labels} value [timestamp]
+func parseSample(line string) (Sample, error) {
+	var sample Sample
+
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return sample, fmt.Errorf("invalid sample %q", line)
+	}
+	sample.Name = line[:end]
+	rest := line[end:]
+
+	// Blanks are allowed between the name and the label set
+	if trimmed := strings.TrimLeft(rest, " \t"); strings.HasPrefix(trimmed, "{") {
+		rest = trimmed
+	}
+
+	if strings.HasPrefix(rest, "{") {
+		labels, remaining, err := parseLabels(rest[1:])
+		if err != nil {
+			return sample, fmt.Errorf("invalid labels in %q: %w", line, err)
+		}
+		sample.Labels = labels
+		rest = remaining
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return sample, fmt.Errorf("missing value in %q", line)
+	}
+
+	value, err := ParseValue(fields[0])
+	if err != nil {
+		return sample, fmt.Errorf("invalid value in %q: %w", line, err)
+	}
+	sample.Value = value
+
+	// Anything after the timestamp (e.g. an exemplar) is ignored
+	if len(fields) > 1 && fields[1] != "#" {
+		ts, err := parseTimestamp(fields[1])
+		if err != nil {
+			return sample, fmt.Errorf("invalid timestamp in %q: %w", line, err)
+		}
+		sample.Timestamp = ts
+		sample.HasTimestamp = true
+	}
+
+	return sample, nil
+}
+
+// parseLabels parses the label set following the opening brace and returns
+// the remaining text after the closing brace
+func parseLabels(s string) ([]Label, string, error) {
+	var labels []Label
+	i := 0
+	// skip advances past blanks, like the expfmt text parser
+	skip := func() {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+	}
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, "", fmt.Errorf("unterminated label set")
+		}
+		if s[i] == '}' {
+			return labels, s[i+1:], nil
+		}
+
+		start := i
+		for i < len(s) && s[i] != '=' && s[i] != ' ' && s[i] != '\t' {
+			i++
+		}
+		name := s[start:i]
+		skip()
+		if i >= len(s) || s[i] != '=' || name == "" {
+			return nil, "", fmt.Errorf("missing '=' in label set")
+		}
+		i++
+		skip()
+		if i >= len(s) || s[i] != '"' {
+			return nil, "", fmt.Errorf("label %s: value must be quoted", name)
+		}
+		i++
+
+		var value strings.Builder
+		closed := false
+		for i < len(s) {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				switch s[i+1] {
+				case 'n':
+					value.WriteByte('\n')
+				case '\\', '"':
+					value.WriteByte(s[i+1])
+				default:
+					value.WriteByte('\\')
+					value.WriteByte(s[i+1])
+				}
+				i += 2
+				continue
+			}
+			i++
+			if c == '"' {
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return nil, "", fmt.Errorf("label %s: unterminated value", name)
+		}
+
+		labels = append(labels, Label{Name: name, Value: value.String()})
+	}
+}
+
+// ParseValue parses a sample value including the special Inf and NaN values
+func ParseValue(s string) (float64, error) {
+	switch s {
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// parseTimestamp accepts both millisecond integers (Prometheus text format)
+// and fractional seconds (OpenMetrics) and returns milliseconds
+func parseTimestamp(s string) (int64, error) {
+	if strings.ContainsAny(s, ".eE") {
+		seconds, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, err
+		}
+		return int64(math.Round(seconds * 1000)), nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// Parse reads every family from r
+func Parse(r io.Reader) ([]*Family, error) {
+	decoder := NewDecoder(r)
+	var families []*Family
+	for {
+		family, err := decoder.Next()
+		if err == io.EOF {
+			return families, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, family)
+	}
+}
+
+// FormatValue formats a sample value the way Prometheus expects it
+func FormatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	// Keep integral values such as timestamps and byte counts out of
+	// exponent notation
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteFamily writes a single family in the Prometheus text format
+func WriteFamily(w io.Writer, family *Family) error {
+	bw, ok := w.(*bufio.Writer)
+	if !ok {
+		bw = bufio.NewWriter(w)
+	}
+
+	if family.Help != "" {
+		fmt.Fprintf(bw, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+	}
+	if family.Type != "" {
+		fmt.Fprintf(bw, "# TYPE %s %s\n", family.Name, family.Type)
+	}
+	for i := range family.Samples {
+		writeSample(bw, &family.Samples[i])
+	}
+
+	if !ok {
+		return bw.Flush()
+	}
+	return nil
+}
+
+// Write writes all families in the Prometheus text format
+func Write(w io.Writer, families []*Family) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		if err := WriteFamily(bw, family); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writeSample writes one sample line
+func writeSample(bw *bufio.Writer, sample *Sample) {
+	bw.WriteString(sample.Name)
+	if len(sample.Labels) > 0 {
+		bw.WriteByte('{')
+		for i, l := range sample.Labels {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			bw.WriteString(l.Name)
+			bw.WriteString(`="`)
+			bw.WriteString(escapeLabelValue(l.Value))
+			bw.WriteByte('"')
+		}
+		bw.WriteByte('}')
+	}
+	bw.WriteByte(' ')
+	bw.WriteString(FormatValue(sample.Value))
+	if sample.HasTimestamp {
+		bw.WriteByte(' ')
+		bw.WriteString(strconv.FormatInt(sample.Timestamp, 10))
+	}
+	bw.WriteByte('\n')
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	helpUnescaper     = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func unescapeHelp(s string) string {
+	return helpUnescaper.Replace(s)
+}
@@ -0,0 +1,125 @@
+package openmetrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTestData(t *testing.T) {
+	files, err := filepath.Glob("../../test_data/*.txt")
+	if err != nil {
+		t.Fatalf("Failed to list test data: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("Expected test data files")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", file, err)
+			}
+
+			families, err := Parse(strings.NewReader(string(data)))
+			if err != nil {
+				t.Fatalf("Failed to parse %s: %v", file, err)
+			}
+
+			var out strings.Builder
+			if err := Write(&out, families); err != nil {
+				t.Fatalf("Failed to write families: %v", err)
+			}
+
+			if out.String() != strings.TrimRight(string(data), "\n")+"\n" {
+				t.Errorf("Round trip of %s does not match the original", file)
+			}
+		})
+	}
+}
+
+func TestParseSample(t *testing.T) {
+	input := `# HELP test_metric A "test" metric\nwith newline
+# TYPE test_metric gauge
+test_metric{node="c1",reason="down, \"maint\""} 1.5 1700000000000
+test_metric +Inf
+orphan_metric 3
+# EOF
+ignored_metric 4
+`
+	families, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	if len(families) != 2 {
+		t.Fatalf("Expected 2 families, got %d", len(families))
+	}
+
+	family := families[0]
+	if family.Name != "test_metric" || family.Type != TypeGauge {
+		t.Errorf("Unexpected family %s of type %s", family.Name, family.Type)
+	}
+	if family.Help != "A \"test\" metric\nwith newline" {
+		t.Errorf("Unexpected help %q", family.Help)
+	}
+	if len(family.Samples) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(family.Samples))
+	}
+
+	sample := family.Samples[0]
+	if reason, _ := sample.Label("reason"); reason != `down, "maint"` {
+		t.Errorf("Unexpected reason label %q", reason)
+	}
+	if sample.Value != 1.5 || !sample.HasTimestamp || sample.Timestamp != 1700000000000 {
+		t.Errorf("Unexpected sample value %v timestamp %d", sample.Value, sample.Timestamp)
+	}
+
+	if families[1].Name != "orphan_metric" || families[1].Type != TypeUntyped {
+		t.Errorf("Expected untyped orphan_metric family, got %s (%s)", families[1].Name, families[1].Type)
+	}
+}
+
+func TestParseLabelBlanks(t *testing.T) {
+	tests := []string{
+		`a{ x = "1" } 1`,
+		`a{x= "1",y ="2"} 1`,
+		"a{\tx\t=\t\"1\"\t,\ty=\"2\" ,} 1",
+		`a {x="1", y="2"} 1`,
+		`a{x="1" , y="2" } 1`,
+	}
+
+	for _, input := range tests {
+		families, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", input, err)
+			continue
+		}
+		sample := families[0].Samples[0]
+		if sample.Name != "a" || sample.Value != 1 {
+			t.Errorf("%q: unexpected sample %s %v", input, sample.Name, sample.Value)
+		}
+		if x, _ := sample.Label("x"); x != "1" {
+			t.Errorf("%q: expected x=1, got %q", input, x)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		`metric{node="c1" 1`,
+		`metric{node=c1} 1`,
+		`metric{="c1"} 1`,
+		`metric{node "c1"} 1`,
+		`metric abc`,
+		`metric`,
+	}
+
+	for _, input := range tests {
+		if _, err := Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected an error parsing %q", input)
+		}
+	}
+}
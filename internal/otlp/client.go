@@ -0,0 +1,116 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// client sends export requests to an OTLP collector
+type client interface {
+	export(ctx context.Context, request *collectorpb.ExportMetricsServiceRequest) (*collectorpb.ExportMetricsServiceResponse, error)
+	close() error
+}
+
+// httpClient posts binary protobuf requests to an OTLP/HTTP endpoint
+type httpClient struct {
+	url       string
+	headers   map[string]string
+	userAgent string
+	client    *http.Client
+}
+
+// newHTTPClient creates a client for the full OTLP/HTTP metrics URL, such as
+// http://collector:4318/v1/metrics
+func newHTTPClient(url string, headers map[string]string, userAgent string) *httpClient {
+	return &httpClient{url: url, headers: headers, userAgent: userAgent, client: &http.Client{}}
+}
+
+func (c *httpClient) export(ctx context.Context, request *collectorpb.ExportMetricsServiceRequest) (*collectorpb.ExportMetricsServiceResponse, error) {
+	body, err := proto.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OTLP request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP request: %w", err)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send OTLP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("OTLP collector returned status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OTLP response: %w", err)
+	}
+	response := &collectorpb.ExportMetricsServiceResponse{}
+	if err := proto.Unmarshal(payload, response); err != nil {
+		return nil, fmt.Errorf("failed to decode OTLP response: %w", err)
+	}
+	return response, nil
+}
+
+func (c *httpClient) close() error { return nil }
+
+// grpcClient calls the MetricsService of an OTLP/gRPC endpoint
+type grpcClient struct {
+	conn    *grpc.ClientConn
+	service collectorpb.MetricsServiceClient
+	headers metadata.MD
+}
+
+// newGRPCClient creates a client for a host:port target. TLS is used unless
+// insecure is set.
+func newGRPCClient(target string, insecureTransport bool, headers map[string]string, userAgent string) (*grpcClient, error) {
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if insecureTransport {
+		creds = insecure.NewCredentials()
+	}
+
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds), grpc.WithUserAgent(userAgent))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP gRPC client: %w", err)
+	}
+	return &grpcClient{
+		conn:    conn,
+		service: collectorpb.NewMetricsServiceClient(conn),
+		headers: metadata.New(headers),
+	}, nil
+}
+
+func (c *grpcClient) export(ctx context.Context, request *collectorpb.ExportMetricsServiceRequest) (*collectorpb.ExportMetricsServiceResponse, error) {
+	if len(c.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, c.headers)
+	}
+	response, err := c.service.Export(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send OTLP request: %w", err)
+	}
+	return response, nil
+}
+
+func (c *grpcClient) close() error { return c.conn.Close() }
@@ -0,0 +1,137 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/metrics"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// Collector provides the metrics to export
+type Collector interface {
+	CollectAll(ctx context.Context) (map[string]string, error)
+}
+
+// Exporter periodically collects metrics and exports them to an OTLP collector
+type Exporter struct {
+	collector Collector
+	client    client
+	registry  *metrics.Registry
+	logger    *slog.Logger
+
+	endpoint string
+	resource map[string]string
+	version  string
+	interval time.Duration
+	timeout  time.Duration
+	starts   *counterStarts
+}
+
+// NewExporter creates an exporter from the OTLP configuration. The labels
+// become resource attributes.
+func NewExporter(cfg config.OTLPConfig, labels map[string]string, coll Collector, registry *metrics.Registry, logger *slog.Logger, version string) (*Exporter, error) {
+	e := &Exporter{
+		collector: coll,
+		registry:  registry,
+		logger:    logger,
+		endpoint:  cfg.Endpoint,
+		resource:  labels,
+		version:   version,
+		starts:    newCounterStarts(time.Now()),
+	}
+
+	var err error
+	if e.interval, err = time.ParseDuration(cfg.Interval); err != nil {
+		return nil, fmt.Errorf("invalid otlp interval: %w", err)
+	}
+	if e.timeout, err = time.ParseDuration(cfg.Timeout); err != nil {
+		return nil, fmt.Errorf("invalid otlp timeout: %w", err)
+	}
+
+	userAgent := "slurm_exporter/" + version
+	switch cfg.Protocol {
+	case "grpc":
+		if e.client, err = newGRPCClient(cfg.Endpoint, cfg.Insecure, cfg.Headers, userAgent); err != nil {
+			return nil, err
+		}
+	case "http":
+		e.client = newHTTPClient(cfg.Endpoint, cfg.Headers, userAgent)
+	default:
+		return nil, fmt.Errorf("unsupported otlp protocol %q", cfg.Protocol)
+	}
+
+	return e, nil
+}
+
+// Run collects and exports metrics every interval until ctx is done
+func (e *Exporter) Run(ctx context.Context) {
+	e.logger.Info("starting OTLP export", "endpoint", e.endpoint, "interval", e.interval)
+	defer e.client.close()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		if err := e.export(ctx); err != nil {
+			e.registry.OTLPFailedExports.Inc()
+			e.logger.Error("failed to export metrics over OTLP", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// export runs one collection and sends it to the collector
+func (e *Exporter) export(ctx context.Context) error {
+	collectCtx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+
+	results, err := e.collector.CollectAll(collectCtx)
+	if err != nil {
+		return fmt.Errorf("failed to collect metrics: %w", err)
+	}
+
+	now := time.Now()
+	var families []*openmetrics.Family
+	for endpoint, text := range results {
+		parsed, err := openmetrics.Parse(strings.NewReader(text))
+		if err != nil {
+			e.logger.Error("failed to parse metrics for OTLP export", "endpoint", endpoint, "error", err)
+			continue
+		}
+		families = append(families, parsed...)
+	}
+
+	startTime := func(sample *openmetrics.Sample) time.Time {
+		return e.starts.startTime(sample, now)
+	}
+	request, points := Convert(families, e.resource, e.version, startTime, now)
+	e.starts.prune(now.Add(-staleExports * e.interval))
+	if points == 0 {
+		return nil
+	}
+
+	exportCtx, cancelExport := context.WithTimeout(ctx, e.timeout)
+	defer cancelExport()
+	response, err := e.client.export(exportCtx, request)
+	if err != nil {
+		return err
+	}
+
+	rejected := response.GetPartialSuccess().GetRejectedDataPoints()
+	if rejected > 0 {
+		e.logger.Warn("OTLP collector rejected data points",
+			"rejected", rejected,
+			"message", response.GetPartialSuccess().GetErrorMessage())
+	}
+	e.registry.OTLPExportedPoints.Add(float64(int64(points) - rejected))
+	return nil
+}
@@ -0,0 +1,134 @@
+// Package otlp exports the collected Slurm families to an OpenTelemetry
+// collector over OTLP/HTTP or OTLP/gRPC
+package otlp
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// scopeName identifies the exporter as the instrumentation scope of the metrics
+const scopeName = "github.com/sckyzo/slurm_prometheus_exporter"
+
+// serviceName is the service.name resource attribute
+const serviceName = "slurm_exporter"
+
+// Convert builds an export request from the families. Gauges and untyped
+// families become OTel gauges and counters become cumulative monotonic sums
+// starting at the time returned by startTime for the series; histograms and
+// summaries are skipped. The resource labels are sent once as resource
+// attributes and removed from the data points. It returns the request and the
+// number of data points it holds.
+func Convert(families []*openmetrics.Family, resource map[string]string, version string, startTime func(sample *openmetrics.Sample) time.Time, now time.Time) (*collectorpb.ExportMetricsServiceRequest, int) {
+	nowNanos := uint64(now.UnixNano())
+
+	var metrics []*metricspb.Metric
+	points := 0
+	for _, family := range families {
+		var dataPoints []*metricspb.NumberDataPoint
+		for i := range family.Samples {
+			sample := &family.Samples[i]
+			if strings.HasSuffix(sample.Name, "_created") {
+				continue
+			}
+
+			point := &metricspb.NumberDataPoint{
+				Attributes:   pointAttributes(sample.Labels, resource),
+				TimeUnixNano: nowNanos,
+				Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: sample.Value},
+			}
+			if sample.HasTimestamp {
+				point.TimeUnixNano = uint64(sample.Timestamp) * uint64(time.Millisecond)
+			}
+			if family.Type == openmetrics.TypeCounter {
+				point.StartTimeUnixNano = uint64(startTime(sample).UnixNano())
+			}
+			dataPoints = append(dataPoints, point)
+		}
+		if len(dataPoints) == 0 {
+			continue
+		}
+
+		metric := &metricspb.Metric{
+			Name:        family.Name,
+			Description: family.Help,
+			Unit:        family.Unit,
+		}
+		switch family.Type {
+		case openmetrics.TypeGauge, openmetrics.TypeUntyped, "":
+			metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: dataPoints}}
+		case openmetrics.TypeCounter:
+			// OTel sums carry their monotonicity instead of the _total suffix
+			metric.Name = strings.TrimSuffix(family.Name, "_total")
+			metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				DataPoints:             dataPoints,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}}
+		default:
+			continue
+		}
+
+		metrics = append(metrics, metric)
+		points += len(dataPoints)
+	}
+
+	request := &collectorpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: resourceAttributes(resource, version)},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: scopeName, Version: version},
+				Metrics: metrics,
+			}},
+		}},
+	}
+	return request, points
+}
+
+// resourceAttributes returns the service attributes followed by the resource
+// labels sorted by name
+func resourceAttributes(resource map[string]string, version string) []*commonpb.KeyValue {
+	attributes := []*commonpb.KeyValue{
+		stringAttribute("service.name", serviceName),
+		stringAttribute("service.version", version),
+	}
+
+	names := make([]string, 0, len(resource))
+	for name := range resource {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		attributes = append(attributes, stringAttribute(name, resource[name]))
+	}
+	return attributes
+}
+
+// pointAttributes converts sample labels to attributes, leaving out the
+// labels already carried by the resource
+func pointAttributes(labels []openmetrics.Label, resource map[string]string) []*commonpb.KeyValue {
+	attributes := make([]*commonpb.KeyValue, 0, len(labels))
+	for _, label := range labels {
+		if value, ok := resource[label.Name]; ok && value == label.Value {
+			continue
+		}
+		attributes = append(attributes, stringAttribute(label.Name, label.Value))
+	}
+	return attributes
+}
+
+// stringAttribute creates a string-valued attribute
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
@@ -0,0 +1,298 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/metrics"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+var (
+	testRegistryOnce sync.Once
+	testRegistry     *metrics.Registry
+)
+
+// fakeCollector is a local stand-in for an OpenTelemetry collector, accepting
+// exports over both OTLP/HTTP and OTLP/gRPC
+type fakeCollector struct {
+	collectorpb.UnimplementedMetricsServiceServer
+
+	mu       sync.Mutex
+	requests []*collectorpb.ExportMetricsServiceRequest
+	headers  []string
+}
+
+func (f *fakeCollector) record(request *collectorpb.ExportMetricsServiceRequest, header string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, request)
+	f.headers = append(f.headers, header)
+}
+
+func (f *fakeCollector) received() ([]*collectorpb.ExportMetricsServiceRequest, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests, f.headers
+}
+
+func (f *fakeCollector) Export(ctx context.Context, request *collectorpb.ExportMetricsServiceRequest) (*collectorpb.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.record(request, strings.Join(md.Get("x-scope-orgid"), ","))
+	return &collectorpb.ExportMetricsServiceResponse{}, nil
+}
+
+func (f *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	request := &collectorpb.ExportMetricsServiceRequest{}
+	if err := proto.Unmarshal(body, request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.record(request, r.Header.Get("X-Scope-OrgID"))
+
+	payload, _ := proto.Marshal(&collectorpb.ExportMetricsServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(payload)
+}
+
+// staticCollector returns the same payload on every collection
+type staticCollector struct {
+	results map[string]string
+}
+
+func (c *staticCollector) CollectAll(ctx context.Context) (map[string]string, error) {
+	return c.results, nil
+}
+
+const testPayload = `# HELP slurm_node_cpus Number of CPUs
+# TYPE slurm_node_cpus gauge
+slurm_node_cpus{node="c001",cluster="c1"} 64
+slurm_node_cpus{node="c002",cluster="c1"} 32 1700000000000
+# HELP slurm_jobs_submitted Submitted jobs
+# TYPE slurm_jobs_submitted counter
+slurm_jobs_submitted_total{cluster="c1"} 1200
+# TYPE slurm_job_duration_seconds histogram
+slurm_job_duration_seconds_bucket{le="+Inf",cluster="c1"} 1
+slurm_job_duration_seconds_sum{cluster="c1"} 10
+slurm_job_duration_seconds_count{cluster="c1"} 1
+`
+
+// attributes flattens attributes into a map
+func attributes(kvs []*commonpb.KeyValue) map[string]string {
+	result := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		result[kv.Key] = kv.Value.GetStringValue()
+	}
+	return result
+}
+
+func TestConvert(t *testing.T) {
+	families, err := openmetrics.Parse(strings.NewReader(testPayload))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	start := time.Unix(1000, 0)
+	now := time.Unix(2000, 0)
+	startTime := func(*openmetrics.Sample) time.Time { return start }
+	request, points := Convert(families, map[string]string{"cluster": "c1"}, "1.2.3", startTime, now)
+	if points != 3 {
+		t.Fatalf("Expected 3 data points, got %d", points)
+	}
+
+	resource := request.ResourceMetrics[0]
+	resourceAttributes := attributes(resource.Resource.Attributes)
+	if resourceAttributes["cluster"] != "c1" || resourceAttributes["service.name"] != "slurm_exporter" || resourceAttributes["service.version"] != "1.2.3" {
+		t.Errorf("Unexpected resource attributes %v", resourceAttributes)
+	}
+
+	metrics := resource.ScopeMetrics[0].Metrics
+	if len(metrics) != 2 {
+		t.Fatalf("Expected the histogram to be skipped, got %d metrics", len(metrics))
+	}
+
+	gauge := metrics[0]
+	if gauge.Name != "slurm_node_cpus" || gauge.Description != "Number of CPUs" || gauge.GetGauge() == nil {
+		t.Fatalf("Unexpected gauge %v", gauge)
+	}
+	points0 := gauge.GetGauge().DataPoints
+	if got := attributes(points0[0].Attributes); len(got) != 1 || got["node"] != "c001" {
+		t.Errorf("Expected the resource label to be removed from data points, got %v", got)
+	}
+	if points0[0].GetAsDouble() != 64 || points0[0].TimeUnixNano != uint64(now.UnixNano()) {
+		t.Errorf("Unexpected data point %v", points0[0])
+	}
+	if points0[1].TimeUnixNano != uint64(1700000000000*time.Millisecond) {
+		t.Errorf("Expected the sample timestamp to be kept, got %d", points0[1].TimeUnixNano)
+	}
+
+	sum := metrics[1]
+	if sum.Name != "slurm_jobs_submitted" || sum.GetSum() == nil {
+		t.Fatalf("Unexpected sum %v", sum)
+	}
+	if !sum.GetSum().IsMonotonic || sum.GetSum().AggregationTemporality != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Errorf("Expected a cumulative monotonic sum, got %v", sum.GetSum())
+	}
+	if point := sum.GetSum().DataPoints[0]; point.GetAsDouble() != 1200 || point.StartTimeUnixNano != uint64(start.UnixNano()) {
+		t.Errorf("Unexpected counter data point %v", point)
+	}
+}
+
+// newTestExporter creates an exporter for the given protocol and endpoint
+func newTestExporter(t *testing.T, protocol, endpoint string) *Exporter {
+	t.Helper()
+
+	testRegistryOnce.Do(func() {
+		testRegistry = metrics.NewRegistry("test", "test", "test", false)
+	})
+
+	cfg := &config.Config{
+		Slurm:     config.SlurmConfig{URL: "http://localhost:6817", Timeout: "5s"},
+		Server:    config.ServerConfig{Port: 8080},
+		Endpoints: []config.EndpointConfig{{Name: "jobs", Path: "/metrics/jobs", Enabled: true}},
+		Labels:    map[string]string{"cluster": "c1"},
+		OTLP: config.OTLPConfig{
+			Enabled:  true,
+			Protocol: protocol,
+			Endpoint: endpoint,
+			Insecure: true,
+			Interval: "1h",
+			Headers:  map[string]string{"X-Scope-OrgID": "hpc"},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Invalid test configuration: %v", err)
+	}
+
+	coll := &staticCollector{results: map[string]string{"jobs": testPayload}}
+	e, err := NewExporter(cfg.OTLP, cfg.Labels, coll, testRegistry, slog.New(slog.NewTextHandler(io.Discard, nil)), "test")
+	if err != nil {
+		t.Fatalf("Failed to create exporter: %v", err)
+	}
+	t.Cleanup(func() { e.client.close() })
+	return e
+}
+
+// checkExport verifies the collector received one request for the test payload
+func checkExport(t *testing.T, fake *fakeCollector) {
+	t.Helper()
+
+	requests, headers := fake.received()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 export, got %d", len(requests))
+	}
+	if headers[0] != "hpc" {
+		t.Errorf("Expected the configured header, got %q", headers[0])
+	}
+	resource := requests[0].ResourceMetrics[0]
+	if attributes(resource.Resource.Attributes)["cluster"] != "c1" {
+		t.Errorf("Expected the cluster resource attribute, got %v", resource.Resource.Attributes)
+	}
+	if n := len(resource.ScopeMetrics[0].Metrics); n != 2 {
+		t.Errorf("Expected 2 metrics, got %d", n)
+	}
+}
+
+func TestExportHTTP(t *testing.T) {
+	fake := &fakeCollector{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	e := newTestExporter(t, "http", server.URL+"/v1/metrics")
+	if err := e.export(context.Background()); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	checkExport(t, fake)
+}
+
+// counterStart returns the start time of the counter data point of an export
+func counterStart(t *testing.T, request *collectorpb.ExportMetricsServiceRequest) uint64 {
+	t.Helper()
+	for _, metric := range request.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if sum := metric.GetSum(); sum != nil {
+			return sum.DataPoints[0].StartTimeUnixNano
+		}
+	}
+	t.Fatalf("No counter in the export")
+	return 0
+}
+
+func TestExportCounterReset(t *testing.T) {
+	fake := &fakeCollector{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	e := newTestExporter(t, "http", server.URL+"/v1/metrics")
+	coll := e.collector.(*staticCollector)
+	payloads := []string{testPayload, testPayload, strings.Replace(testPayload, "} 1200", "} 5", 1)}
+	for _, payload := range payloads {
+		coll.results = map[string]string{"jobs": payload}
+		if err := e.export(context.Background()); err != nil {
+			t.Fatalf("Export failed: %v", err)
+		}
+	}
+
+	requests, _ := fake.received()
+	first, second, reset := counterStart(t, requests[0]), counterStart(t, requests[1]), counterStart(t, requests[2])
+	if first != second {
+		t.Errorf("Expected the start time to be kept while the counter grows, got %d then %d", first, second)
+	}
+	if reset <= second {
+		t.Errorf("Expected the start time to move after the counter reset, got %d then %d", second, reset)
+	}
+	if len(e.starts.series) != 1 {
+		t.Errorf("Expected 1 tracked counter series, got %d", len(e.starts.series))
+	}
+}
+
+func TestExportHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	e := newTestExporter(t, "http", server.URL+"/v1/metrics")
+	err := e.export(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("Expected the collector error, got %v", err)
+	}
+}
+
+func TestExportGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	fake := &fakeCollector{}
+	server := grpc.NewServer()
+	collectorpb.RegisterMetricsServiceServer(server, fake)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	e := newTestExporter(t, "grpc", listener.Addr().String())
+	if err := e.export(context.Background()); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	checkExport(t, fake)
+}
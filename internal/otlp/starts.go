@@ -0,0 +1,56 @@
+package otlp
+
+import (
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// staleExports is the number of exports after which a series that was not
+// seen again is forgotten
+const staleExports = 5
+
+// counterStarts keeps the start time and last value of every counter series,
+// so that a counter reset starts a new cumulative sequence
+type counterStarts struct {
+	start  time.Time
+	series map[string]*counterSeries
+}
+
+// counterSeries is the state of one counter series
+type counterSeries struct {
+	start time.Time
+	value float64
+	seen  time.Time
+}
+
+// newCounterStarts creates the state of an exporter started at start
+func newCounterStarts(start time.Time) *counterStarts {
+	return &counterStarts{start: start, series: make(map[string]*counterSeries)}
+}
+
+// startTime returns the start time of the series of the sample and records
+// its value. New series start with the exporter; a series whose value
+// decreased was reset and starts now.
+func (c *counterStarts) startTime(sample *openmetrics.Sample, now time.Time) time.Time {
+	key := sample.Key()
+	series, ok := c.series[key]
+	if !ok {
+		series = &counterSeries{start: c.start}
+		c.series[key] = series
+	} else if sample.Value < series.value {
+		series.start = now
+	}
+	series.value = sample.Value
+	series.seen = now
+	return series.start
+}
+
+// prune forgets the series not seen since before the given time
+func (c *counterStarts) prune(before time.Time) {
+	for key, series := range c.series {
+		if series.seen.Before(before) {
+			delete(c.series, key)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Package pushgateway pushes collected metrics to a Prometheus Pushgateway
+package pushgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GroupingLabel is one label of the grouping key, in URL order
+type GroupingLabel struct {
+	Name  string
+	Value string
+}
+
+// URL builds the Pushgateway URL of the group identified by job and the
+// grouping labels. Values that are empty or contain a slash are base64
+// encoded as the Pushgateway requires.
+func URL(gateway, job string, grouping []GroupingLabel) string {
+	var path strings.Builder
+	path.WriteString(strings.TrimSuffix(gateway, "/"))
+	path.WriteString("/metrics")
+	writeSegment(&path, "job", job)
+	for _, label := range grouping {
+		writeSegment(&path, label.Name, label.Value)
+	}
+	return path.String()
+}
+
+// writeSegment appends one name/value pair of the grouping key
+func writeSegment(path *strings.Builder, name, value string) {
+	if value == "" || strings.Contains(value, "/") {
+		fmt.Fprintf(path, "/%s@base64/%s", name, base64.RawURLEncoding.EncodeToString([]byte(value)))
+		if value == "" {
+			// An empty value is encoded as a single "="
+			path.WriteString("=")
+		}
+		return
+	}
+	fmt.Fprintf(path, "/%s/%s", name, url.PathEscape(value))
+}
+
+// Push replaces the metrics of the group with the given text exposition
+func Push(ctx context.Context, client *http.Client, groupURL string, metrics []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, groupURL, bytes.NewReader(metrics))
+	if err != nil {
+		return fmt.Errorf("failed to create push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushgateway returned status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
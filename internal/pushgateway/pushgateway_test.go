@@ -0,0 +1,56 @@
+package pushgateway
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		grouping []GroupingLabel
+		expected string
+	}{
+		{
+			[]GroupingLabel{{"cluster", "c1"}, {"instance", "login01"}},
+			"http://pg:9091/metrics/job/slurm/cluster/c1/instance/login01",
+		},
+		{
+			[]GroupingLabel{{"cluster", "a/b"}, {"instance", ""}},
+			"http://pg:9091/metrics/job/slurm/cluster@base64/YS9i/instance@base64/=",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := URL("http://pg:9091/", "slurm", tt.grouping); got != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+func TestPush(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.EscapedPath()
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		if r.URL.Path == "/metrics/job/fail" {
+			http.Error(w, "pushed metrics are invalid", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	groupURL := URL(server.URL, "slurm", []GroupingLabel{{"cluster", "c1"}, {"instance", "login01"}})
+	if err := Push(context.Background(), server.Client(), groupURL, []byte("slurm_jobs 1\n")); err != nil {
+		t.Fatalf("Failed to push: %v", err)
+	}
+	if method != http.MethodPut || path != "/metrics/job/slurm/cluster/c1/instance/login01" || body != "slurm_jobs 1\n" {
+		t.Errorf("Unexpected push %s %s %q", method, path, body)
+	}
+
+	if err := Push(context.Background(), server.Client(), URL(server.URL, "fail", nil), nil); err == nil {
+		t.Errorf("Expected the pushgateway error to be returned")
+	}
+}
@@ -0,0 +1,85 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+// recoverableError marks failed pushes worth retrying, such as network
+// errors, 5xx responses and rate limiting
+type recoverableError struct {
+	err error
+}
+
+func (e *recoverableError) Error() string { return e.err.Error() }
+func (e *recoverableError) Unwrap() error { return e.err }
+
+// Client sends write requests to a remote-write receiver
+type Client struct {
+	url       string
+	headers   map[string]string
+	basicAuth config.BasicAuthConfig
+	userAgent string
+	client    *http.Client
+}
+
+// NewClient creates a client for the configured receiver
+func NewClient(cfg config.RemoteWriteConfig, version string) (*Client, error) {
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote write timeout: %w", err)
+	}
+
+	return &Client{
+		url:       cfg.URL,
+		headers:   cfg.Headers,
+		basicAuth: cfg.BasicAuth,
+		userAgent: "slurm_exporter/" + version,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Send compresses a serialized WriteRequest with snappy and posts it
+func (c *Client) Send(ctx context.Context, request []byte) error {
+	body := snappy.Encode(nil, request)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create remote write request: %w", err)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if c.basicAuth.Enabled {
+		req.SetBasicAuth(c.basicAuth.Username, c.basicAuth.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return &recoverableError{fmt.Errorf("failed to send remote write request: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("remote write receiver returned status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return &recoverableError{err}
+	}
+	return err
+}
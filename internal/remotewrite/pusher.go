@@ -0,0 +1,194 @@
+package remotewrite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/backoff"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/metrics"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// Collector provides the metrics to push
+type Collector interface {
+	CollectAll(ctx context.Context) (map[string]string, error)
+}
+
+// batch is one encoded collection waiting to be sent
+type batch struct {
+	request []byte
+	samples int
+}
+
+// Pusher periodically collects metrics and sends them to a remote-write
+// receiver. Batches wait in a bounded in-memory queue: when the receiver is
+// down for long, the oldest batches are dropped.
+type Pusher struct {
+	collector Collector
+	client    *Client
+	registry  *metrics.Registry
+	logger    *slog.Logger
+
+	interval       time.Duration
+	timeout        time.Duration
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+
+	queue chan batch
+}
+
+// NewPusher creates a pusher from the remote write configuration
+func NewPusher(cfg config.RemoteWriteConfig, coll Collector, registry *metrics.Registry, logger *slog.Logger, version string) (*Pusher, error) {
+	client, err := NewClient(cfg, version)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pusher{
+		collector:   coll,
+		client:      client,
+		registry:    registry,
+		logger:      logger,
+		maxAttempts: cfg.Retry.MaxAttempts,
+		queue:       make(chan batch, cfg.QueueSize),
+	}
+
+	if p.interval, err = time.ParseDuration(cfg.Interval); err != nil {
+		return nil, fmt.Errorf("invalid remote write interval: %w", err)
+	}
+	if p.timeout, err = time.ParseDuration(cfg.Timeout); err != nil {
+		return nil, fmt.Errorf("invalid remote write timeout: %w", err)
+	}
+	if p.initialBackoff, err = time.ParseDuration(cfg.Retry.InitialBackoff); err != nil {
+		return nil, fmt.Errorf("invalid remote write initial_backoff: %w", err)
+	}
+	if p.maxBackoff, err = time.ParseDuration(cfg.Retry.MaxBackoff); err != nil {
+		return nil, fmt.Errorf("invalid remote write max_backoff: %w", err)
+	}
+	if p.maxAttempts < 1 {
+		p.maxAttempts = 1
+	}
+
+	return p, nil
+}
+
+// Run collects and pushes metrics every interval until ctx is done
+func (p *Pusher) Run(ctx context.Context) {
+	p.logger.Info("starting remote write", "url", p.client.url, "interval", p.interval)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.send(ctx)
+	}()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.collect(ctx)
+
+		select {
+		case <-ctx.Done():
+			<-done
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect runs one collection and queues the encoded result
+func (p *Pusher) collect(ctx context.Context) {
+	collectCtx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+
+	results, err := p.collector.CollectAll(collectCtx)
+	if err != nil {
+		p.logger.Error("failed to collect metrics for remote write", "error", err)
+		return
+	}
+
+	timestamp := time.Now().UnixMilli()
+	var families []*openmetrics.Family
+	for endpoint, text := range results {
+		parsed, err := openmetrics.Parse(strings.NewReader(text))
+		if err != nil {
+			p.logger.Error("failed to parse metrics for remote write", "endpoint", endpoint, "error", err)
+			continue
+		}
+		families = append(families, parsed...)
+	}
+
+	request, samples := Encode(families, timestamp)
+	if samples == 0 {
+		return
+	}
+	p.enqueue(batch{request: request, samples: samples})
+}
+
+// enqueue adds a batch to the queue, dropping the oldest batches when full
+func (p *Pusher) enqueue(b batch) {
+	for {
+		select {
+		case p.queue <- b:
+			p.registry.RemoteWriteQueueLength.Set(float64(len(p.queue)))
+			return
+		default:
+		}
+
+		select {
+		case dropped := <-p.queue:
+			p.registry.RemoteWriteDropped.Inc()
+			p.logger.Warn("remote write queue is full, dropping oldest batch", "samples", dropped.samples)
+		default:
+		}
+	}
+}
+
+// send delivers queued batches until ctx is done
+func (p *Pusher) send(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case b := <-p.queue:
+			p.registry.RemoteWriteQueueLength.Set(float64(len(p.queue)))
+			if err := p.sendWithRetry(ctx, b); err != nil {
+				p.registry.RemoteWriteFailures.Inc()
+				p.logger.Error("failed to push metrics", "samples", b.samples, "error", err)
+				continue
+			}
+			p.registry.RemoteWriteSamples.Add(float64(b.samples))
+		}
+	}
+}
+
+// sendWithRetry sends a batch, retrying recoverable failures with a jittered
+// exponential backoff
+func (p *Pusher) sendWithRetry(ctx context.Context, b batch) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		sendCtx, cancel := context.WithTimeout(ctx, p.timeout)
+		err = p.client.Send(sendCtx, b.request)
+		cancel()
+
+		var recoverable *recoverableError
+		if err == nil || !errors.As(err, &recoverable) || attempt >= p.maxAttempts {
+			return err
+		}
+
+		p.logger.Debug("retrying remote write", "attempt", attempt+1, "error", err)
+		timer := time.NewTimer(backoff.Jittered(p.initialBackoff, p.maxBackoff, attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package remotewrite pushes collected metrics to Prometheus remote-write
+// receivers
+package remotewrite
+
+import (
+	"math"
+	"sort"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the remote-write 1.0 protobuf messages
+const (
+	writeRequestTimeseries = 1
+
+	timeSeriesLabels  = 1
+	timeSeriesSamples = 2
+
+	labelName  = 1
+	labelValue = 2
+
+	sampleValue     = 1
+	sampleTimestamp = 2
+)
+
+// nameLabel carries the metric name of a remote-write series
+const nameLabel = "__name__"
+
+// Encode converts families to a serialized remote-write WriteRequest with one
+// time series per sample. Samples without a timestamp are stamped with the
+// given time in milliseconds. It also returns the number of samples encoded.
+func Encode(families []*openmetrics.Family, timestamp int64) ([]byte, int) {
+	var request []byte
+	var series, labels []byte
+	count := 0
+
+	for _, family := range families {
+		for i := range family.Samples {
+			sample := &family.Samples[i]
+
+			series = series[:0]
+			for _, label := range sortedLabels(sample) {
+				labels = labels[:0]
+				labels = protowire.AppendTag(labels, labelName, protowire.BytesType)
+				labels = protowire.AppendString(labels, label.Name)
+				labels = protowire.AppendTag(labels, labelValue, protowire.BytesType)
+				labels = protowire.AppendString(labels, label.Value)
+
+				series = protowire.AppendTag(series, timeSeriesLabels, protowire.BytesType)
+				series = protowire.AppendBytes(series, labels)
+			}
+
+			ts := timestamp
+			if sample.HasTimestamp {
+				ts = sample.Timestamp
+			}
+			var value []byte
+			value = protowire.AppendTag(value, sampleValue, protowire.Fixed64Type)
+			value = protowire.AppendFixed64(value, math.Float64bits(sample.Value))
+			value = protowire.AppendTag(value, sampleTimestamp, protowire.VarintType)
+			value = protowire.AppendVarint(value, uint64(ts))
+
+			series = protowire.AppendTag(series, timeSeriesSamples, protowire.BytesType)
+			series = protowire.AppendBytes(series, value)
+
+			request = protowire.AppendTag(request, writeRequestTimeseries, protowire.BytesType)
+			request = protowire.AppendBytes(request, series)
+			count++
+		}
+	}
+
+	return request, count
+}
+
+// sortedLabels returns the labels of a sample including the metric name,
+// sorted by name as receivers expect
+func sortedLabels(sample *openmetrics.Sample) []openmetrics.Label {
+	labels := make([]openmetrics.Label, 0, len(sample.Labels)+1)
+	labels = append(labels, openmetrics.Label{Name: nameLabel, Value: sample.Name})
+	labels = append(labels, sample.Labels...)
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels
+}
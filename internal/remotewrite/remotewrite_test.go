@@ -0,0 +1,304 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/metrics"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+var (
+	testRegistryOnce sync.Once
+	testRegistry     *metrics.Registry
+)
+
+// series is a decoded remote-write time series with a single sample
+type series struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// decodeWriteRequest parses a serialized WriteRequest
+func decodeWriteRequest(t *testing.T, request []byte) []series {
+	t.Helper()
+
+	var result []series
+	for _, field := range fields(t, request) {
+		s := series{labels: make(map[string]string)}
+		for _, part := range fields(t, field.bytes) {
+			switch part.number {
+			case timeSeriesLabels:
+				var name, value string
+				for _, label := range fields(t, part.bytes) {
+					if label.number == labelName {
+						name = string(label.bytes)
+					} else {
+						value = string(label.bytes)
+					}
+				}
+				s.labels[name] = value
+			case timeSeriesSamples:
+				for _, sample := range fields(t, part.bytes) {
+					if sample.number == sampleValue {
+						s.value = math.Float64frombits(sample.fixed)
+					} else {
+						s.timestamp = int64(sample.varint)
+					}
+				}
+			}
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// field is a decoded protobuf field
+type field struct {
+	number protowire.Number
+	bytes  []byte
+	fixed  uint64
+	varint uint64
+}
+
+// fields splits a protobuf message into its fields
+func fields(t *testing.T, message []byte) []field {
+	t.Helper()
+
+	var result []field
+	for len(message) > 0 {
+		number, wireType, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			t.Fatalf("Invalid tag: %v", protowire.ParseError(n))
+		}
+		message = message[n:]
+
+		f := field{number: number}
+		switch wireType {
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(message)
+		case protowire.Fixed64Type:
+			f.fixed, n = protowire.ConsumeFixed64(message)
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(message)
+		default:
+			t.Fatalf("Unexpected wire type %d", wireType)
+		}
+		if n < 0 {
+			t.Fatalf("Invalid field: %v", protowire.ParseError(n))
+		}
+		message = message[n:]
+		result = append(result, f)
+	}
+	return result
+}
+
+// receiver is an in-process remote-write receiver failing the first requests
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	series   []series
+	t        *testing.T
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+
+	if req.Header.Get("Content-Encoding") != "snappy" || req.Header.Get("Content-Type") != "application/x-protobuf" {
+		http.Error(w, "unexpected headers", http.StatusBadRequest)
+		return
+	}
+	if r.failures > 0 {
+		r.failures--
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	compressed, _ := io.ReadAll(req.Body)
+	request, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.series = append(r.series, decodeWriteRequest(r.t, request)...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// staticCollector returns the same payload on every collection
+type staticCollector struct {
+	collections atomic.Int32
+	results     map[string]string
+}
+
+func (c *staticCollector) CollectAll(ctx context.Context) (map[string]string, error) {
+	c.collections.Add(1)
+	return c.results, nil
+}
+
+// newTestPusher creates a pusher for the given receiver URL
+func newTestPusher(t *testing.T, url string, coll Collector, mutate func(*config.RemoteWriteConfig)) *Pusher {
+	t.Helper()
+
+	testRegistryOnce.Do(func() {
+		testRegistry = metrics.NewRegistry("test", "test", "test", false)
+	})
+
+	cfg := &config.Config{
+		Slurm:     config.SlurmConfig{URL: "http://localhost:6817", Timeout: "5s"},
+		Server:    config.ServerConfig{Port: 8080},
+		Endpoints: []config.EndpointConfig{{Name: "jobs", Path: "/metrics/jobs", Enabled: true}},
+		RemoteWrite: config.RemoteWriteConfig{
+			Enabled:  true,
+			URL:      url,
+			Interval: "1h",
+			Retry:    config.RetryConfig{InitialBackoff: "1ms", MaxBackoff: "5ms"},
+		},
+	}
+	if mutate != nil {
+		mutate(&cfg.RemoteWrite)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Invalid test configuration: %v", err)
+	}
+
+	p, err := NewPusher(cfg.RemoteWrite, coll, testRegistry, slog.New(slog.NewTextHandler(io.Discard, nil)), "test")
+	if err != nil {
+		t.Fatalf("Failed to create pusher: %v", err)
+	}
+	return p
+}
+
+func TestEncode(t *testing.T) {
+	families, err := openmetrics.Parse(strings.NewReader(`# TYPE slurm_node_cpus gauge
+slurm_node_cpus{node="c001",cluster="c1"} 64
+slurm_node_cpus{node="c002",cluster="c1"} 32 1700000000000
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	request, count := Encode(families, 1234)
+	if count != 2 {
+		t.Fatalf("Expected 2 samples, got %d", count)
+	}
+
+	decoded := decodeWriteRequest(t, request)
+	want := []series{
+		{labels: map[string]string{"__name__": "slurm_node_cpus", "node": "c001", "cluster": "c1"}, value: 64, timestamp: 1234},
+		{labels: map[string]string{"__name__": "slurm_node_cpus", "node": "c002", "cluster": "c1"}, value: 32, timestamp: 1700000000000},
+	}
+	for i, s := range decoded {
+		if s.value != want[i].value || s.timestamp != want[i].timestamp || len(s.labels) != len(want[i].labels) {
+			t.Errorf("Series %d: expected %+v, got %+v", i, want[i], s)
+		}
+		for name, value := range want[i].labels {
+			if s.labels[name] != value {
+				t.Errorf("Series %d: expected label %s=%q, got %q", i, name, value, s.labels[name])
+			}
+		}
+	}
+
+	// Labels must be sorted by name
+	first := fields(t, fields(t, request)[0].bytes)
+	var names []string
+	for _, part := range first {
+		if part.number == timeSeriesLabels {
+			names = append(names, string(fields(t, part.bytes)[0].bytes))
+		}
+	}
+	if strings.Join(names, ",") != "__name__,cluster,node" {
+		t.Errorf("Expected sorted labels, got %v", names)
+	}
+}
+
+func TestPusherRetries(t *testing.T) {
+	recv := &receiver{failures: 2, t: t}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	coll := &staticCollector{results: map[string]string{
+		"jobs": "# TYPE slurm_jobs gauge\nslurm_jobs 3\n",
+	}}
+	p := newTestPusher(t, server.URL, coll, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		recv.mu.Lock()
+		received := len(recv.series)
+		recv.mu.Unlock()
+		if received > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	recv.mu.Lock()
+	defer recv.mu.Unlock()
+	if recv.requests != 3 {
+		t.Errorf("Expected 2 failed attempts and 1 success, got %d requests", recv.requests)
+	}
+	if len(recv.series) != 1 || recv.series[0].labels["__name__"] != "slurm_jobs" || recv.series[0].value != 3 {
+		t.Errorf("Unexpected series %+v", recv.series)
+	}
+}
+
+func TestPusherDoesNotRetryClientErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	p := newTestPusher(t, server.URL, &staticCollector{}, nil)
+	err := p.sendWithRetry(context.Background(), batch{request: []byte{}, samples: 1})
+	if err == nil || !strings.Contains(err.Error(), "out of order sample") {
+		t.Errorf("Expected the receiver error, got %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected a single attempt, got %d", n)
+	}
+}
+
+func TestPusherQueueDropsOldest(t *testing.T) {
+	p := newTestPusher(t, "http://127.0.0.1:1", &staticCollector{}, func(cfg *config.RemoteWriteConfig) {
+		cfg.QueueSize = 2
+	})
+
+	for i := 1; i <= 3; i++ {
+		p.enqueue(batch{samples: i})
+	}
+
+	if len(p.queue) != 2 {
+		t.Fatalf("Expected the queue to stay bounded, got %d batches", len(p.queue))
+	}
+	if first := <-p.queue; first.samples != 2 {
+		t.Errorf("Expected the oldest batch to be dropped, got batch %d first", first.samples)
+	}
+}
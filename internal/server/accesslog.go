@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// accessRecord collects the details of a request filled in by the handlers
+// it goes through
+type accessRecord struct {
+	user      string
+	endpoints []string
+}
+
+// accessRecordKey is the context key of the access record
+type accessRecordKey struct{}
+
+// SetAccessLog enables the access log, writing one JSON line per request to w
+func (s *Server) SetAccessLog(w io.Writer) {
+	s.accessLog = slog.New(slog.NewJSONHandler(w, nil))
+}
+
+// parseTrustedProxies converts IP addresses and CIDR ranges to prefixes.
+// Invalid entries are rejected by the configuration validation.
+func parseTrustedProxies(entries []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return prefixes
+}
+
+// accessLogMiddleware logs every request once it has been served
+func (s *Server) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		record := &accessRecord{}
+		wrw := &responseWriterWrapper{ResponseWriter: w, statusCode: http.StatusOK}
+
+		next.ServeHTTP(wrw, r.WithContext(context.WithValue(r.Context(), accessRecordKey{}, record)))
+
+		attrs := []slog.Attr{
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("client", s.clientAddress(r)),
+		}
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			attrs = append(attrs, slog.String("x_forwarded_for", forwarded))
+		}
+		if record.user != "" {
+			attrs = append(attrs, slog.String("user", record.user))
+		}
+		attrs = append(attrs,
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", wrw.statusCode),
+			slog.Int64("bytes", wrw.bytes),
+			slog.Float64("duration_seconds", time.Since(start).Seconds()),
+			slog.String("user_agent", r.UserAgent()),
+		)
+		if len(record.endpoints) > 0 {
+			attrs = append(attrs, slog.Any("endpoints", record.endpoints))
+		}
+
+		s.accessLog.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+	})
+}
+
+// clientAddress returns the address of the client. When the request comes
+// from a trusted proxy, X-Forwarded-For is walked from the right, skipping
+// trusted proxies, up to the first untrusted address.
+func (s *Server) clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	client, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	client = client.Unmap()
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0 && s.isTrustedProxy(client); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = hop.Unmap()
+	}
+	return client.String()
+}
+
+// isTrustedProxy reports whether addr belongs to a trusted proxy
+func (s *Server) isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordUser stores the authenticated user in the access record, if any
+func recordUser(r *http.Request, user string) {
+	if record, ok := r.Context().Value(accessRecordKey{}).(*accessRecord); ok {
+		record.user = user
+	}
+}
+
+// recordEndpoints stores the collected endpoints in the access record, if any
+func recordEndpoints(r *http.Request, endpoints []string) {
+	if record, ok := r.Context().Value(accessRecordKey{}).(*accessRecord); ok {
+		record.endpoints = endpoints
+	}
+}
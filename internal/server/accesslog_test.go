@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+func TestAccessLog(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("# TYPE slurm_jobs gauge\nslurm_jobs 3\n"))
+	}))
+	defer upstream.Close()
+
+	srv := newTestServer(t, upstream.URL, func(cfg *config.Config) {
+		cfg.Server.BasicAuth = config.BasicAuthConfig{Enabled: true, Username: "admin", Password: "secret"}
+		cfg.Server.AccessLog = config.AccessLogConfig{Enabled: true}
+	})
+	var out bytes.Buffer
+	srv.SetAccessLog(&out)
+	handler := srv.Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics/jobs", nil)
+	req.RemoteAddr = "192.0.2.10:51234"
+	req.SetBasicAuth("admin", "secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.RemoteAddr = "192.0.2.11:51234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 access log lines, got %d: %s", len(lines), out.String())
+	}
+
+	var entry struct {
+		Msg        string   `json:"msg"`
+		RemoteAddr string   `json:"remote_addr"`
+		Client     string   `json:"client"`
+		User       string   `json:"user"`
+		Path       string   `json:"path"`
+		Status     int      `json:"status"`
+		Bytes      int64    `json:"bytes"`
+		Duration   *float64 `json:"duration_seconds"`
+		Endpoints  []string `json:"endpoints"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Invalid JSON line: %v", err)
+	}
+	if entry.Msg != "request" || entry.RemoteAddr != "192.0.2.10:51234" || entry.Client != "192.0.2.10" ||
+		entry.User != "admin" || entry.Path != "/metrics/jobs" || entry.Status != http.StatusOK ||
+		entry.Bytes == 0 || entry.Duration == nil || len(entry.Endpoints) != 1 || entry.Endpoints[0] != "jobs" {
+		t.Errorf("Unexpected access log entry %s", lines[0])
+	}
+
+	entry.User, entry.Endpoints = "", nil
+	json.Unmarshal([]byte(lines[1]), &entry)
+	if entry.Status != http.StatusUnauthorized || entry.User != "" || entry.Endpoints != nil {
+		t.Errorf("Unexpected access log entry for a rejected request %s", lines[1])
+	}
+}
+
+func TestClientAddress(t *testing.T) {
+	srv := newTestServer(t, "http://127.0.0.1:1", func(cfg *config.Config) {
+		cfg.Server.AccessLog = config.AccessLogConfig{
+			Enabled:        true,
+			TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"},
+		}
+	})
+	srv.SetAccessLog(&bytes.Buffer{})
+
+	tests := []struct {
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"198.51.100.7:1234", "", "198.51.100.7"},
+		// Untrusted peers cannot spoof their address
+		{"198.51.100.7:1234", "203.0.113.9", "198.51.100.7"},
+		{"192.0.2.1:1234", "203.0.113.9", "203.0.113.9"},
+		// Trusted hops are skipped from the right
+		{"192.0.2.1:1234", "203.0.113.9, 198.51.100.1, 10.1.2.3", "198.51.100.1"},
+		// Everything trusted: the leftmost address is the client
+		{"10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "10.0.0.3"},
+		// A malformed hop stops the walk at the last valid address
+		{"10.0.0.1:1234", "203.0.113.9, bogus", "10.0.0.1"},
+		{"[::ffff:10.0.0.1]:1234", "203.0.113.9", "203.0.113.9"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := srv.clientAddress(req); got != tt.want {
+			t.Errorf("%s with X-Forwarded-For %q: expected %s, got %s", tt.remoteAddr, tt.forwarded, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// apiResponse is the envelope of every /api/v1 response
+type apiResponse struct {
+	Status string `json:"status"`
+	Data   any    `json:"data,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// apiFamily is the JSON representation of a metric family
+type apiFamily struct {
+	Name    string      `json:"name"`
+	Help    string      `json:"help,omitempty"`
+	Type    string      `json:"type"`
+	Unit    string      `json:"unit,omitempty"`
+	Samples []apiSample `json:"samples"`
+}
+
+// apiSample is the JSON representation of a sample. NaN and infinite values
+// have no JSON number and are encoded as null.
+type apiSample struct {
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels"`
+	Value     *float64          `json:"value"`
+	Timestamp *int64            `json:"timestamp,omitempty"`
+}
+
+// labelMatcher selects samples by the value of a label, like a PromQL
+// matcher. A missing label matches as the empty string.
+type labelMatcher struct {
+	name    string
+	op      string
+	value   string
+	pattern *regexp.Regexp
+}
+
+// matcherPattern splits a matcher such as state="running" or node=~"gpu.*"
+var matcherPattern = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*(".*")\s*$`)
+
+// parseMatcher parses a single label matcher
+func parseMatcher(expr string) (*labelMatcher, error) {
+	parts := matcherPattern.FindStringSubmatch(expr)
+	if parts == nil {
+		return nil, fmt.Errorf("invalid matcher %q, expected e.g. state=\"running\"", expr)
+	}
+	value, err := strconv.Unquote(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid matcher %q: %w", expr, err)
+	}
+
+	m := &labelMatcher{name: parts[1], op: parts[2], value: value}
+	if m.op == "=~" || m.op == "!~" {
+		// Anchored like PromQL
+		if m.pattern, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid matcher %q: %w", expr, err)
+		}
+	}
+	return m, nil
+}
+
+// matches reports whether the sample satisfies the matcher
+func (m *labelMatcher) matches(sample *openmetrics.Sample) bool {
+	value, _ := sample.Label(m.name)
+	switch m.op {
+	case "=":
+		return value == m.value
+	case "!=":
+		return value != m.value
+	case "=~":
+		return m.pattern.MatchString(value)
+	default:
+		return !m.pattern.MatchString(value)
+	}
+}
+
+// handleAPIMetrics returns a handler serving the parsed families as JSON. The
+// prefix parameter keeps the families whose name starts with one of the given
+// prefixes, each match[] parameter keeps the samples satisfying a label
+// matcher, and collect[] restricts the collection like on /metrics.
+func (s *Server) handleAPIMetrics() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		endpoints, err := s.selectEndpoints(query["collect[]"])
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var matchers []*labelMatcher
+		for _, expr := range query["match[]"] {
+			matcher, err := parseMatcher(expr)
+			if err != nil {
+				writeAPIError(w, http.StatusBadRequest, err)
+				return
+			}
+			matchers = append(matchers, matcher)
+		}
+
+		recordEndpoints(r, endpointNames(endpoints))
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		families, err := s.collector.CollectFamilies(ctx, endpoints)
+		if err != nil {
+			s.logger.Error("failed to collect metrics", "path", r.URL.Path, "error", err)
+			writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("failed to collect metrics"))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, apiResponse{
+			Status: "success",
+			Data:   filterFamilies(families, query["prefix"], matchers),
+		})
+	})
+}
+
+// filterFamilies converts the families kept by the prefixes and matchers.
+// Families left without samples are dropped.
+func filterFamilies(families []*openmetrics.Family, prefixes []string, matchers []*labelMatcher) []apiFamily {
+	result := []apiFamily{}
+	for _, family := range families {
+		if !hasAnyPrefix(family.Name, prefixes) {
+			continue
+		}
+
+		converted := apiFamily{Name: family.Name, Help: family.Help, Type: family.Type, Unit: family.Unit}
+		for i := range family.Samples {
+			sample := &family.Samples[i]
+			if !matchesAll(sample, matchers) {
+				continue
+			}
+			converted.Samples = append(converted.Samples, toAPISample(sample))
+		}
+		if len(converted.Samples) > 0 {
+			result = append(result, converted)
+		}
+	}
+	return result
+}
+
+// hasAnyPrefix reports whether name starts with one of the prefixes, or
+// whether no prefix is given
+func hasAnyPrefix(name string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAll reports whether the sample satisfies every matcher
+func matchesAll(sample *openmetrics.Sample, matchers []*labelMatcher) bool {
+	for _, matcher := range matchers {
+		if !matcher.matches(sample) {
+			return false
+		}
+	}
+	return true
+}
+
+// toAPISample converts a sample to its JSON representation
+func toAPISample(sample *openmetrics.Sample) apiSample {
+	converted := apiSample{Name: sample.Name, Labels: make(map[string]string, len(sample.Labels))}
+	for _, label := range sample.Labels {
+		converted.Labels[label.Name] = label.Value
+	}
+	if !math.IsNaN(sample.Value) && !math.IsInf(sample.Value, 0) {
+		value := sample.Value
+		converted.Value = &value
+	}
+	if sample.HasTimestamp {
+		timestamp := sample.Timestamp
+		converted.Timestamp = &timestamp
+	}
+	return converted
+}
+
+// writeAPIError writes an error response
+func writeAPIError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, apiResponse{Status: "error", Error: err.Error()})
+}
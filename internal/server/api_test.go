@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIMetrics(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`# HELP slurm_node_cpus Number of CPUs
+# TYPE slurm_node_cpus gauge
+slurm_node_cpus{node="c001",partition="cpu"} 64
+slurm_node_cpus{node="g001",partition="gpu"} 32
+slurm_node_cpus{node="g002",partition="gpu"} NaN
+# TYPE slurm_jobs gauge
+slurm_jobs{state="running"} 3
+`))
+	}))
+	defer upstream.Close()
+
+	handler := newTestServer(t, upstream.URL, nil).Handler()
+
+	tests := []struct {
+		target   string
+		code     int
+		families []string
+		samples  int
+	}{
+		{"/api/v1/metrics", http.StatusOK, []string{"slurm_node_cpus", "slurm_jobs"}, 4},
+		{"/api/v1/metrics?prefix=slurm_node_", http.StatusOK, []string{"slurm_node_cpus"}, 3},
+		{`/api/v1/metrics?match[]=partition="gpu"`, http.StatusOK, []string{"slurm_node_cpus"}, 2},
+		{`/api/v1/metrics?match[]=partition=~"c.*"&match[]=node!="c002"`, http.StatusOK, []string{"slurm_node_cpus"}, 1},
+		{`/api/v1/metrics?match[]=partition!~"gpu"`, http.StatusOK, []string{"slurm_node_cpus", "slurm_jobs"}, 2},
+		{"/api/v1/metrics?prefix=slurm_partition_", http.StatusOK, nil, 0},
+		{"/api/v1/metrics?match[]=partition", http.StatusBadRequest, nil, 0},
+		{`/api/v1/metrics?match[]=node=~"("`, http.StatusBadRequest, nil, 0},
+		{"/api/v1/metrics?collect[]=unknown", http.StatusBadRequest, nil, 0},
+	}
+
+	for _, tt := range tests {
+		response := get(t, handler, tt.target)
+		if response.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.target, tt.code, response.Code)
+			continue
+		}
+
+		var body struct {
+			Status string      `json:"status"`
+			Data   []apiFamily `json:"data"`
+			Error  string      `json:"error"`
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", tt.target, err)
+		}
+		if tt.code != http.StatusOK {
+			if body.Status != "error" || body.Error == "" {
+				t.Errorf("%s: expected an error body, got %+v", tt.target, body)
+			}
+			continue
+		}
+
+		if len(body.Data) != len(tt.families) {
+			t.Errorf("%s: expected families %v, got %+v", tt.target, tt.families, body.Data)
+			continue
+		}
+		samples := 0
+		for i, family := range body.Data {
+			if family.Name != tt.families[i] {
+				t.Errorf("%s: expected family %s, got %s", tt.target, tt.families[i], family.Name)
+			}
+			samples += len(family.Samples)
+		}
+		if samples != tt.samples {
+			t.Errorf("%s: expected %d samples, got %d", tt.target, tt.samples, samples)
+		}
+	}
+
+	// Metadata and values are carried over; NaN becomes null
+	response := get(t, handler, "/api/v1/metrics?prefix=slurm_node_cpus")
+	var body struct {
+		Data []apiFamily `json:"data"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &body)
+	family := body.Data[0]
+	if family.Help != "Number of CPUs" || family.Type != "gauge" {
+		t.Errorf("Unexpected family metadata %+v", family)
+	}
+	if v := family.Samples[0].Value; v == nil || *v != 64 || family.Samples[0].Labels["node"] != "c001" {
+		t.Errorf("Unexpected sample %+v", family.Samples[0])
+	}
+	if family.Samples[2].Value != nil {
+		t.Errorf("Expected NaN to be encoded as null, got %v", *family.Samples[2].Value)
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"html/template"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+//go:embed templates/debug.html
+var debugTemplateText string
+
+// debugTemplate renders the raw and transformed payloads of an endpoint
+var debugTemplate = template.Must(template.New("debug").Funcs(template.FuncMap{
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Microsecond).String()
+	},
+}).Parse(debugTemplateText))
+
+// debugScrape is the JSON representation of a debug scrape
+type debugScrape struct {
+	Endpoint       string              `json:"endpoint"`
+	URL            string              `json:"url"`
+	Status         string              `json:"status,omitempty"`
+	Headers        map[string][]string `json:"headers,omitempty"`
+	Raw            string              `json:"raw"`
+	Output         string              `json:"output"`
+	Series         int                 `json:"series"`
+	FetchSeconds   float64             `json:"fetch_seconds"`
+	ProcessSeconds float64             `json:"process_seconds"`
+	Error          string              `json:"error,omitempty"`
+}
+
+// registerDebugRoutes adds the endpoint debug pages and pprof to the mux
+// when enabled
+func (s *Server) registerDebugRoutes(mux *http.ServeMux) {
+	if s.config.Server.Debug.Endpoints {
+		for _, endpoint := range s.config.GetEnabledEndpoints() {
+			mux.Handle("/debug/endpoints/"+endpoint.Name, s.scrapeHandler(s.handleDebugEndpoint(endpoint)))
+		}
+	}
+
+	if s.config.Server.Debug.Pprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+}
+
+// handleDebugEndpoint returns a handler scraping an endpoint once and showing
+// the raw upstream response next to the exporter output, as HTML or as JSON
+// with format=json
+func (s *Server) handleDebugEndpoint(endpoint config.EndpointConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recordEndpoints(r, []string{endpoint.Name})
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		scrape := s.collector.DebugEndpoint(ctx, endpoint)
+
+		if r.URL.Query().Get("format") == "json" {
+			writeJSON(w, http.StatusOK, debugScrape{
+				Endpoint:       scrape.Endpoint,
+				URL:            scrape.URL,
+				Status:         scrape.Status,
+				Headers:        scrape.Headers,
+				Raw:            scrape.Raw,
+				Output:         scrape.Output,
+				Series:         scrape.Series,
+				FetchSeconds:   scrape.FetchDuration.Seconds(),
+				ProcessSeconds: scrape.ProcessDuration.Seconds(),
+				Error:          scrape.Error,
+			})
+			return
+		}
+
+		var buffer bytes.Buffer
+		if err := debugTemplate.Execute(&buffer, scrape); err != nil {
+			s.logger.Error("failed to render debug page", "endpoint", endpoint.Name, "error", err)
+			http.Error(w, "failed to render debug page", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buffer.Bytes())
+	})
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+func TestDebugEndpoint(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics/broken" {
+			w.Header().Set("X-Slurm-Error", "yes")
+			http.Error(w, "slurmdbd is down", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Slurm-Version", "25.11")
+		w.Write([]byte("# TYPE slurm_jobs_running gauge\nslurm_jobs_running 3\n"))
+	}))
+	defer upstream.Close()
+
+	srv := newTestServer(t, upstream.URL, func(cfg *config.Config) {
+		cfg.Endpoints = []config.EndpointConfig{
+			{Name: "jobs", Path: "/metrics/jobs", Enabled: true},
+			{Name: "broken", Path: "/metrics/broken", Enabled: true},
+		}
+		cfg.Labels = map[string]string{"cluster": "c1"}
+		cfg.Server.BasicAuth = config.BasicAuthConfig{Enabled: true, Username: "admin", Password: "secret"}
+		cfg.Server.Debug = config.DebugConfig{Endpoints: true, Pprof: true}
+	})
+	handler := srv.Handler()
+
+	request := func(target string, authenticated bool) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if authenticated {
+			req.SetBasicAuth("admin", "secret")
+		}
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if code := request("/debug/endpoints/jobs", false).Code; code != http.StatusUnauthorized {
+		t.Errorf("Expected the debug page to require authentication, got %d", code)
+	}
+
+	page := request("/debug/endpoints/jobs", true)
+	if page.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", page.Code)
+	}
+	for _, want := range []string{"200 OK", "X-Slurm-Version", "slurm_jobs_running 3", `slurm_jobs_running{cluster=&#34;c1&#34;} 3`} {
+		if !strings.Contains(page.Body.String(), want) {
+			t.Errorf("Expected %q in the debug page", want)
+		}
+	}
+
+	var scrape debugScrape
+	if err := json.Unmarshal(request("/debug/endpoints/broken?format=json", true).Body.Bytes(), &scrape); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if scrape.Status != "500 Internal Server Error" || !strings.Contains(scrape.Raw, "slurmdbd is down") ||
+		scrape.Headers["X-Slurm-Error"][0] != "yes" || scrape.Error == "" || scrape.Output != "" {
+		t.Errorf("Unexpected debug scrape %+v", scrape)
+	}
+
+	if code := request("/debug/pprof/", true).Code; code != http.StatusOK {
+		t.Errorf("Expected pprof to be mounted, got %d", code)
+	}
+}
+
+func TestDebugRoutesDisabled(t *testing.T) {
+	handler := newTestServer(t, "http://127.0.0.1:1", nil).Handler()
+	for _, target := range []string{"/debug/endpoints/jobs", "/debug/pprof/"} {
+		if code := get(t, handler, target).Code; code != http.StatusNotFound {
+			t.Errorf("%s: expected 404 when disabled, got %d", target, code)
+		}
+	}
+}
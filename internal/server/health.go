@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// endpointHealth is the readiness detail of a single endpoint. The probe is
+// served without credentials, so errors, which may name upstream URLs, are
+// left to the status page.
+type endpointHealth struct {
+	OK          bool       `json:"ok"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	Stale       bool       `json:"stale"`
+}
+
+// readiness is the body of the /-/ready response
+type readiness struct {
+	Status      string                    `json:"status"`
+	LastSuccess *time.Time                `json:"last_success,omitempty"`
+	Endpoints   map[string]endpointHealth `json:"endpoints"`
+}
+
+// handleHealthy returns a handler reporting that the process is alive
+func (s *Server) handleHealthy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+	}
+}
+
+// handleReady returns a handler reporting whether slurmctld answered a scrape
+// or health check within the configured maximum age. When no recent success is
+// known, a health check is run before answering.
+func (s *Server) handleReady() http.HandlerFunc {
+	maxAge, _ := time.ParseDuration(s.config.Server.ReadyMaxAge)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		body := readiness{Status: "ready", Endpoints: make(map[string]endpointHealth)}
+
+		lastSuccess := s.collector.LastSuccess()
+		if time.Since(lastSuccess) > maxAge {
+			ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+			defer cancel()
+			if err := s.collector.Health(ctx); err != nil {
+				s.logger.Debug("readiness health check failed", "error", err)
+			}
+			lastSuccess = s.collector.LastSuccess()
+		}
+
+		if !lastSuccess.IsZero() {
+			body.LastSuccess = &lastSuccess
+		}
+		for _, status := range s.collector.EndpointStatuses() {
+			detail := endpointHealth{OK: !status.LastAttempt.IsZero() && status.LastError == "", Stale: status.Stale}
+			if !status.LastAttempt.IsZero() {
+				detail.LastAttempt = &status.LastAttempt
+			}
+			if !status.LastSuccess.IsZero() {
+				detail.LastSuccess = &status.LastSuccess
+			}
+			body.Endpoints[status.Name] = detail
+		}
+
+		code := http.StatusOK
+		if time.Since(lastSuccess) > maxAge {
+			body.Status = "not ready"
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, body)
+	}
+}
+
+// writeJSON encodes body as the JSON response
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+func TestReadiness(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("# TYPE slurm_jobs gauge\nslurm_jobs 1\n"))
+	}))
+	defer upstream.Close()
+
+	srv := newTestServer(t, upstream.URL, func(cfg *config.Config) {
+		cfg.Server.BasicAuth = config.BasicAuthConfig{Enabled: true, Username: "admin", Password: "secret"}
+	})
+	handler := srv.Handler()
+
+	if code := get(t, handler, "/-/healthy").Code; code != http.StatusOK {
+		t.Errorf("Expected /-/healthy to answer 200 without credentials, got %d", code)
+	}
+
+	srv.collector.CollectAll(context.Background())
+	response := get(t, handler, "/-/ready")
+	if response.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready while slurmctld fails, got %d: %s", response.Code, response.Body)
+	}
+
+	var body readiness
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode readiness: %v", err)
+	}
+	if body.Status != "not ready" {
+		t.Errorf("Unexpected readiness %+v", body)
+	}
+	jobs, ok := body.Endpoints["jobs"]
+	if !ok || jobs.OK || jobs.LastAttempt == nil {
+		t.Errorf("Expected the jobs endpoint to be reported as failing, got %+v", body.Endpoints)
+	}
+
+	// Errors name upstream URLs and stay behind basic auth
+	response = get(t, handler, "/-/ready")
+	if text := response.Body.String(); strings.Contains(text, upstream.URL) || strings.Contains(text, "status code") {
+		t.Errorf("Expected no error detail in the unauthenticated probe, got %s", text)
+	}
+
+	// A successful scrape makes the exporter ready without a health check
+	down.Store(false)
+	srv.collector.CollectAll(context.Background())
+	down.Store(true)
+	response = get(t, handler, "/-/ready")
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected ready after a recent successful scrape, got %d: %s", response.Code, response.Body)
+	}
+	body = readiness{}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode readiness: %v", err)
+	}
+	if jobs := body.Endpoints["jobs"]; !jobs.OK || jobs.LastSuccess == nil {
+		t.Errorf("Expected the jobs endpoint to be reported as ok, got %+v", jobs)
+	}
+}
@@ -4,14 +4,18 @@ import (
 	"context"
 	"crypto/subtle"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sckyzo/slurm_prometheus_exporter/internal/collector"
 	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/formats"
 	"github.com/sckyzo/slurm_prometheus_exporter/internal/metrics"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
 )
 
 // Server represents the HTTP server for the exporter
@@ -22,26 +26,55 @@ type Server struct {
 	logger    *slog.Logger
 	server    *http.Server
 	version   string
+
+	accessLog      *slog.Logger
+	trustedProxies []netip.Prefix
+	limiter        *rateLimiter
+	scrapeSlots    chan struct{}
 }
 
 // NewServer creates a new HTTP server
 func NewServer(cfg *config.Config, coll *collector.Collector, reg *metrics.Registry, logger *slog.Logger, version string) *Server {
-	return &Server{
-		config:    cfg,
-		collector: coll,
-		registry:  reg,
-		logger:    logger,
-		version:   version,
+	s := &Server{
+		config:         cfg,
+		collector:      coll,
+		registry:       reg,
+		logger:         logger,
+		version:        version,
+		trustedProxies: parseTrustedProxies(cfg.Server.AccessLog.TrustedProxies),
+	}
+	if limit := cfg.Server.RateLimit; limit.RequestsPerSecond > 0 {
+		s.limiter = newRateLimiter(limit.RequestsPerSecond, limit.Burst)
 	}
+	if cfg.Server.MaxConcurrentScrapes > 0 {
+		s.scrapeSlots = make(chan struct{}, cfg.Server.MaxConcurrentScrapes)
+	}
+	return s
 }
 
-// Start starts the HTTP server
-func (s *Server) Start() error {
+// Handler builds the HTTP handler serving every route of the exporter
+func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 
 	// Register handlers
-	mux.HandleFunc("/", s.handleLandingPage())
-	mux.Handle("/metrics", s.instrumentHandler(s.handleMetrics()))
+	mux.Handle("/{$}", s.instrumentHandler(s.handleLandingPage()))
+	mux.Handle("/metrics", s.scrapeHandler(s.handleMetrics()))
+	for _, endpoint := range s.config.GetEnabledEndpoints() {
+		mux.Handle("/metrics/"+endpoint.Name, s.scrapeHandler(s.handleEndpointMetrics(endpoint)))
+	}
+	mux.Handle("/api/v1/metrics", s.scrapeHandler(s.handleAPIMetrics()))
+	if influx := s.config.Formats.InfluxDB; influx.Enabled {
+		mux.Handle("/export/influxdb", s.scrapeHandler(s.handleFormat(func(w io.Writer, families []*openmetrics.Family, now time.Time) error {
+			return formats.WriteInfluxDB(w, families, influx, now)
+		})))
+	}
+	if graphite := s.config.Formats.Graphite; graphite.Enabled {
+		mux.Handle("/export/graphite", s.scrapeHandler(s.handleFormat(func(w io.Writer, families []*openmetrics.Family, now time.Time) error {
+			return formats.WriteGraphite(w, families, graphite, now)
+		})))
+	}
+
+	s.registerDebugRoutes(mux)
 
 	// Wrap with basic auth if enabled
 	var handler http.Handler = mux
@@ -49,10 +82,29 @@ func (s *Server) Start() error {
 		handler = s.basicAuthMiddleware(mux)
 	}
 
+	// The rate limit comes first so that it also slows down password guessing
+	if s.limiter != nil {
+		handler = s.rateLimitMiddleware(handler)
+	}
+
+	// Probes stay reachable without credentials
+	root := http.NewServeMux()
+	root.Handle("/-/healthy", s.instrumentHandler(s.handleHealthy()))
+	root.Handle("/-/ready", s.instrumentHandler(s.handleReady()))
+	root.Handle("/", handler)
+
+	if s.accessLog != nil {
+		return s.accessLogMiddleware(root)
+	}
+	return root
+}
+
+// Start starts the HTTP server
+func (s *Server) Start() error {
 	addr := fmt.Sprintf(":%d", s.config.Server.Port)
 	s.server = &http.Server{
 		Addr:         addr,
-		Handler:      handler,
+		Handler:      s.Handler(),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -80,109 +132,109 @@ func (s *Server) Stop(ctx context.Context) error {
 	return s.server.Shutdown(ctx)
 }
 
-// handleLandingPage returns a handler for the landing page
-func (s *Server) handleLandingPage() http.HandlerFunc {
-	landingPageHTML := `<!DOCTYPE html>
-<html>
-<head>
-    <title>Slurm Exporter</title>
-    <style>
-        body {
-            font-family: Arial, sans-serif;
-            max-width: 800px;
-            margin: 50px auto;
-            padding: 20px;
-            background-color: #f5f5f5;
-        }
-        .container {
-            background-color: white;
-            padding: 30px;
-            border-radius: 8px;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
-        }
-        h1 {
-            color: #333;
-            border-bottom: 2px solid #007bff;
-            padding-bottom: 10px;
-        }
-        .info {
-            margin: 20px 0;
-            padding: 15px;
-            background-color: #e7f3ff;
-            border-left: 4px solid #007bff;
-            border-radius: 4px;
-        }
-        a {
-            color: #007bff;
-            text-decoration: none;
-        }
-        a:hover {
-            text-decoration: underline;
-        }
-        .version {
-            color: #666;
-            font-size: 0.9em;
-            margin-top: 20px;
-            padding-top: 20px;
-            border-top: 1px solid #ddd;
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <h1>🚀 Slurm Exporter</h1>
-        <div class="info">
-            <p>Welcome to the Slurm Metrics Exporter for Prometheus!</p>
-            <p>This exporter collects metrics from Slurm and exposes them in Prometheus format.</p>
-        </div>
-        <h2>Available Endpoints:</h2>
-        <ul>
-            <li><a href="/metrics">/metrics</a> - Prometheus metrics endpoint</li>
-        </ul>
-        <div class="version">
-            <strong>Version:</strong> %s
-        </div>
-    </div>
-</body>
-</html>`
-
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
+// handleMetrics returns a handler for the metrics endpoint. The collect[]
+// query parameter restricts the scrape to the named endpoints.
+func (s *Server) handleMetrics() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoints, err := s.selectEndpoints(r.URL.Query()["collect[]"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		fmt.Fprintf(w, landingPageHTML, s.version)
-	}
+		recordEndpoints(r, endpointNames(endpoints))
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		// Stream metrics from the selected endpoints in Prometheus format
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := s.collector.WriteEndpoints(ctx, w, endpoints); err != nil {
+			s.logger.Error("failed to write metrics", "error", err)
+			return
+		}
+
+		// Also expose the exporter's own metrics
+		promhttp.Handler().ServeHTTP(w, r)
+	})
 }
 
-// handleMetrics returns a handler for the metrics endpoint
-func (s *Server) handleMetrics() http.Handler {
+// handleEndpointMetrics returns a handler serving the metrics of a single endpoint
+func (s *Server) handleEndpointMetrics(endpoint config.EndpointConfig) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recordEndpoints(r, []string{endpoint.Name})
+
 		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 		defer cancel()
 
-		// Collect metrics from all endpoints
-		metricsMap, err := s.collector.CollectAll(ctx)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := s.collector.WriteEndpoints(ctx, w, []config.EndpointConfig{endpoint}); err != nil {
+			s.logger.Error("failed to write metrics", "endpoint", endpoint.Name, "error", err)
+		}
+	})
+}
+
+// handleFormat returns a handler rendering the parsed families of the
+// selected endpoints with render. Like /metrics it honours collect[].
+func (s *Server) handleFormat(render func(io.Writer, []*openmetrics.Family, time.Time) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoints, err := s.selectEndpoints(r.URL.Query()["collect[]"])
 		if err != nil {
-			s.logger.Error("failed to collect metrics", "error", err)
-			http.Error(w, "Failed to collect metrics", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		recordEndpoints(r, endpointNames(endpoints))
 
-		// Write metrics in Prometheus format
-		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
-		if err := s.collector.WriteMetrics(w, metricsMap); err != nil {
-			s.logger.Error("failed to write metrics", "error", err)
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		families, err := s.collector.CollectFamilies(ctx, endpoints)
+		if err != nil {
+			s.logger.Error("failed to collect metrics", "path", r.URL.Path, "error", err)
+			http.Error(w, "failed to collect metrics", http.StatusInternalServerError)
 			return
 		}
 
-		// Also expose the exporter's own metrics
-		promhttp.Handler().ServeHTTP(w, r)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := render(w, families, time.Now()); err != nil {
+			s.logger.Error("failed to write metrics", "path", r.URL.Path, "error", err)
+		}
 	})
 }
 
+// selectEndpoints resolves the endpoint names requested with collect[]. All
+// enabled endpoints are selected when no name is given.
+func (s *Server) selectEndpoints(names []string) ([]config.EndpointConfig, error) {
+	if len(names) == 0 {
+		return s.config.GetEnabledEndpoints(), nil
+	}
+
+	var endpoints []config.EndpointConfig
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		endpoint, ok := s.config.GetEnabledEndpoint(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown or disabled endpoint %q", name)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// endpointNames returns the names of the endpoints
+func endpointNames(endpoints []config.EndpointConfig) []string {
+	names := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		names[i] = endpoint.Name
+	}
+	return names
+}
+
 // basicAuthMiddleware implements HTTP Basic Authentication
 func (s *Server) basicAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -201,6 +253,7 @@ func (s *Server) basicAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		recordUser(r, username)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -231,15 +284,25 @@ func (s *Server) instrumentHandler(handler http.Handler) http.Handler {
 	})
 }
 
+// scrapeHandler instruments a handler querying slurmctld and subjects it to
+// the concurrent scrape limit
+func (s *Server) scrapeHandler(handler http.Handler) http.Handler {
+	return s.instrumentHandler(s.limitScrapes(handler))
+}
+
 // responseWriterWrapper wraps http.ResponseWriter to capture the status code
+// and the size of the body
 type responseWriterWrapper struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode  int
+	wroteHeader bool
+	bytes       int64
 }
 
 // WriteHeader captures the status code
 func (w *responseWriterWrapper) WriteHeader(statusCode int) {
-	if w.statusCode == 0 {
+	if !w.wroteHeader {
+		w.wroteHeader = true
 		w.statusCode = statusCode
 		w.ResponseWriter.WriteHeader(statusCode)
 	}
@@ -247,9 +310,10 @@ func (w *responseWriterWrapper) WriteHeader(statusCode int) {
 
 // Write ensures that if WriteHeader wasn't called, we default to 200
 func (w *responseWriterWrapper) Write(b []byte) (int, error) {
-	if w.statusCode == 0 {
-		w.statusCode = http.StatusOK
-		w.ResponseWriter.WriteHeader(http.StatusOK)
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
 	}
-	return w.ResponseWriter.Write(b)
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
 }
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/collector"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/metrics"
+)
+
+var (
+	testRegistryOnce sync.Once
+	testRegistry     *metrics.Registry
+)
+
+// newTestServer creates a server scraping the given upstream URL, sharing a
+// single metrics registry since the exporter metrics are registered globally
+func newTestServer(t *testing.T, url string, mutate func(*config.Config)) *Server {
+	t.Helper()
+
+	testRegistryOnce.Do(func() {
+		testRegistry = metrics.NewRegistry("test", "test", "test", false)
+	})
+
+	cfg := &config.Config{
+		Slurm:  config.SlurmConfig{URL: url, Timeout: "5s"},
+		Server: config.ServerConfig{Port: 8080},
+		Endpoints: []config.EndpointConfig{
+			{Name: "jobs", Path: "/metrics/jobs", Enabled: true},
+		},
+	}
+	if mutate != nil {
+		mutate(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Invalid test configuration: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	coll, err := collector.NewCollector(cfg, testRegistry, logger)
+	if err != nil {
+		t.Fatalf("Failed to create collector: %v", err)
+	}
+	return NewServer(cfg, coll, testRegistry, logger, "test")
+}
+
+// get performs a request against the server handler
+func get(t *testing.T, handler http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	return recorder
+}
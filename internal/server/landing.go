@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"net/http"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/collector"
+)
+
+//go:embed templates/landing.html
+var landingTemplateText string
+
+// landingTemplate renders the status page served on /
+var landingTemplate = template.Must(template.New("landing").Funcs(template.FuncMap{
+	"ago": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return time.Since(t).Round(time.Second).String() + " ago"
+	},
+	"seconds": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+}).Parse(landingTemplateText))
+
+// landingPage is the data of the status page
+type landingPage struct {
+	Version   string
+	Endpoints []collector.EndpointStatus
+	Upstreams []collector.UpstreamStatus
+	Summary   collector.ClusterSummary
+	InfluxDB  bool
+	Graphite  bool
+	Config    string
+}
+
+// handleLandingPage returns a handler for the status page: the outcome of
+// the latest scrape of each endpoint, the health of every Slurm URL as seen
+// by the latest scrapes and health checks, the node and job counts of the
+// latest scrapes and the configuration with its secrets redacted. It never
+// queries slurmctld itself.
+func (s *Server) handleLandingPage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config, err := yaml.Marshal(s.config.Redacted())
+		if err != nil {
+			s.logger.Error("failed to encode configuration", "error", err)
+		}
+
+		page := landingPage{
+			Version:   s.version,
+			Endpoints: s.collector.EndpointStatuses(),
+			Upstreams: s.collector.UpstreamStatuses(),
+			Summary:   s.collector.Summary(),
+			InfluxDB:  s.config.Formats.InfluxDB.Enabled,
+			Graphite:  s.config.Formats.Graphite.Enabled,
+			Config:    string(config),
+		}
+
+		var buffer bytes.Buffer
+		if err := landingTemplate.Execute(&buffer, page); err != nil {
+			s.logger.Error("failed to render landing page", "error", err)
+			http.Error(w, "failed to render landing page", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buffer.Bytes())
+	}
+}
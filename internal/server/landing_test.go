@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+func TestLandingPage(t *testing.T) {
+	var probes atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics/nodes" {
+			w.Write([]byte("# TYPE slurm_nodes gauge\nslurm_nodes 4\n# TYPE slurm_nodes_idle gauge\nslurm_nodes_idle 3\n# TYPE slurm_nodes_down gauge\nslurm_nodes_down 1\n"))
+			return
+		}
+		if r.URL.Path == "/" {
+			probes.Add(1)
+			return
+		}
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	srv := newTestServer(t, upstream.URL, func(cfg *config.Config) {
+		cfg.Endpoints = []config.EndpointConfig{
+			{Name: "nodes", Path: "/metrics/nodes", Enabled: true},
+			{Name: "jobs", Path: "/metrics/jobs", Enabled: true},
+			{Name: "partitions", Path: "/metrics/partitions", Enabled: true},
+		}
+		cfg.Server.BasicAuth = config.BasicAuthConfig{Enabled: true, Username: "admin", Password: "hunter2"}
+	})
+	handler := srv.Handler()
+
+	request := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetBasicAuth("admin", "hunter2")
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+	request("/metrics?collect[]=nodes&collect[]=jobs")
+
+	served := testutil.ToFloat64(testRegistry.HTTPRequestsTotal.WithLabelValues(http.MethodGet, "/", "200"))
+	response := request("/")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", response.Code)
+	}
+	body := response.Body.String()
+
+	for _, want := range []string{
+		`<a href="/metrics/nodes">nodes</a>`,
+		`<span class="ok">ok</span>`,
+		`<span class="failed">failed</span>`,
+		`<span class="muted">not scraped</span>`,
+		"unexpected status code: 500",
+		`<span class="ok">reachable</span>`,
+		"<tr><th>Nodes</th><th>4</th></tr>",
+		"<tr><td>idle</td><td>3</td></tr>",
+		"<tr><td>down</td><td>1</td></tr>",
+		"username: admin",
+		"password: &lt;secret&gt;",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in the landing page", want)
+		}
+	}
+	if got := testutil.ToFloat64(testRegistry.HTTPRequestsTotal.WithLabelValues(http.MethodGet, "/", "200")); got != served+1 {
+		t.Errorf("Expected the landing page request to be instrumented, got %v", got-served)
+	}
+	if n := probes.Load(); n != 0 {
+		t.Errorf("Expected the landing page to use the recorded upstream health, got %d health checks", n)
+	}
+	if strings.Contains(body, "hunter2") {
+		t.Errorf("Expected the basic auth password to be redacted")
+	}
+
+	if code := request("/unknown").Code; code != http.StatusNotFound {
+		t.Errorf("Expected unknown paths to return 404, got %d", code)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+func TestEndpointSelection(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/metrics/")
+		w.Write([]byte("# TYPE slurm_" + name + " gauge\nslurm_" + name + " 1\n"))
+	}))
+	defer upstream.Close()
+
+	srv := newTestServer(t, upstream.URL, func(cfg *config.Config) {
+		cfg.Endpoints = []config.EndpointConfig{
+			{Name: "nodes", Path: "/metrics/nodes", Enabled: true},
+			{Name: "scheduler", Path: "/metrics/scheduler", Enabled: true},
+			{Name: "partitions", Path: "/metrics/partitions", Enabled: false},
+		}
+	})
+	handler := srv.Handler()
+
+	tests := []struct {
+		target   string
+		code     int
+		included []string
+		excluded []string
+	}{
+		{"/metrics", http.StatusOK, []string{"slurm_nodes 1", "slurm_scheduler 1", "slurm_exporter_build_info"}, nil},
+		{"/metrics?collect[]=nodes", http.StatusOK, []string{"slurm_nodes 1"}, []string{"slurm_scheduler 1"}},
+		{"/metrics?collect[]=nodes&collect[]=scheduler", http.StatusOK, []string{"slurm_nodes 1", "slurm_scheduler 1"}, nil},
+		{"/metrics?collect[]=partitions", http.StatusBadRequest, nil, nil},
+		{"/metrics?collect[]=unknown", http.StatusBadRequest, nil, nil},
+		{"/metrics/scheduler", http.StatusOK, []string{"slurm_scheduler 1"}, []string{"slurm_nodes 1", "slurm_exporter_build_info"}},
+		{"/metrics/partitions", http.StatusNotFound, nil, nil},
+	}
+
+	for _, tt := range tests {
+		response := get(t, handler, tt.target)
+		if response.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.target, tt.code, response.Code)
+			continue
+		}
+		body := response.Body.String()
+		for _, want := range tt.included {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: expected %q in the response", tt.target, want)
+			}
+		}
+		for _, unwanted := range tt.excluded {
+			if strings.Contains(body, unwanted) {
+				t.Errorf("%s: unexpected %q in the response", tt.target, unwanted)
+			}
+		}
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("# TYPE slurm_jobs gauge\nslurm_jobs{state=\"running\"} 3 1700000000000\n"))
+	}))
+	defer upstream.Close()
+
+	srv := newTestServer(t, upstream.URL, func(cfg *config.Config) {
+		cfg.Formats.InfluxDB.Enabled = true
+		cfg.Formats.Graphite = config.GraphiteFormatConfig{Enabled: true, Prefix: "hpc"}
+	})
+	handler := srv.Handler()
+
+	tests := []struct {
+		target string
+		code   int
+		want   string
+	}{
+		{"/export/influxdb", http.StatusOK, "slurm_jobs,state=running value=3 1700000000000000000\n"},
+		{"/export/graphite?collect[]=jobs", http.StatusOK, "hpc.slurm_jobs.running 3 1700000000\n"},
+		{"/export/graphite?collect[]=unknown", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		response := get(t, handler, tt.target)
+		if response.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.target, tt.code, response.Code)
+			continue
+		}
+		if tt.want != "" && response.Body.String() != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.target, tt.want, response.Body.String())
+		}
+	}
+
+	disabled := newTestServer(t, upstream.URL, nil).Handler()
+	if code := get(t, disabled, "/export/influxdb").Code; code != http.StatusNotFound {
+		t.Errorf("Expected disabled formats to return 404, got %d", code)
+	}
+}
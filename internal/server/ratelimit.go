@@ -0,0 +1,128 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Reasons of the rejected requests metric
+const (
+	rejectRateLimit     = "rate_limit"
+	rejectMaxConcurrent = "max_concurrent_scrapes"
+)
+
+// rateLimiter keeps a token bucket per client
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// tokenBucket holds the tokens of a client at the time of its last request
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a limiter refilling rate tokens per second, up to
+// burst tokens
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token from the bucket of the client. When the bucket is
+// empty, it returns false and the time until the next token.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = bucket
+	}
+
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := (1 - bucket.tokens) / l.rate
+		return false, time.Duration(wait * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep forgets the clients whose bucket has refilled, at most once per
+// refill period, so that the map does not grow with every client ever seen
+func (l *rateLimiter) sweep(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) < refill {
+		return
+	}
+	l.lastSweep = now
+
+	for client, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= refill {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// rateLimitMiddleware rejects the requests of clients over their rate limit
+// with 429 Too Many Requests
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := s.clientAddress(r)
+		if ok, wait := s.limiter.allow(client, time.Now()); !ok {
+			s.registry.HTTPRejectedRequests.WithLabelValues(rejectRateLimit).Inc()
+			s.logger.Debug("request rejected by the rate limit", "client", client, "path", r.URL.Path)
+			reject(w, http.StatusTooManyRequests, wait)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitScrapes rejects scrapes beyond the maximum number of concurrent
+// scrapes with 503 Service Unavailable instead of queueing them
+func (s *Server) limitScrapes(next http.Handler) http.Handler {
+	if s.scrapeSlots == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case s.scrapeSlots <- struct{}{}:
+			defer func() { <-s.scrapeSlots }()
+		default:
+			s.registry.HTTPRejectedRequests.WithLabelValues(rejectMaxConcurrent).Inc()
+			s.logger.Debug("scrape rejected by the concurrency limit", "path", r.URL.Path)
+			reject(w, http.StatusServiceUnavailable, time.Second)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reject answers with the status code and a Retry-After header, in whole
+// seconds rounded up
+func reject(w http.ResponseWriter, statusCode int, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, http.StatusText(statusCode), statusCode)
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(2, 2)
+	now := time.Unix(1700000000, 0)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow("a", now); !ok {
+			t.Fatalf("Request %d within the burst was rejected", i+1)
+		}
+	}
+	ok, wait := limiter.allow("a", now)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("Expected a rejection with a 500ms wait, got %v and %v", ok, wait)
+	}
+
+	// Other clients have their own bucket
+	if ok, _ := limiter.allow("b", now); !ok {
+		t.Errorf("Expected another client to be allowed")
+	}
+
+	// One token is back after 500ms
+	if ok, _ := limiter.allow("a", now.Add(500*time.Millisecond)); !ok {
+		t.Errorf("Expected a request to be allowed once a token was refilled")
+	}
+	if ok, _ := limiter.allow("a", now.Add(500*time.Millisecond)); ok {
+		t.Errorf("Expected the bucket to be empty again")
+	}
+
+	// Buckets that have refilled are forgotten
+	limiter.allow("c", now.Add(time.Minute))
+	if len(limiter.buckets) != 1 {
+		t.Errorf("Expected only the last client to be tracked, got %d buckets", len(limiter.buckets))
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("# TYPE slurm_jobs gauge\nslurm_jobs 1\n"))
+	}))
+	defer upstream.Close()
+
+	srv := newTestServer(t, upstream.URL, func(cfg *config.Config) {
+		cfg.Server.RateLimit = config.RateLimitConfig{RequestsPerSecond: 0.5}
+	})
+	handler := srv.Handler()
+	rejected := testutil.ToFloat64(testRegistry.HTTPRejectedRequests.WithLabelValues(rejectRateLimit))
+
+	if response := get(t, handler, "/metrics/jobs"); response.Code != http.StatusOK {
+		t.Fatalf("Expected the first request to succeed, got %d", response.Code)
+	}
+	response := get(t, handler, "/metrics/jobs")
+	if response.Code != http.StatusTooManyRequests || response.Header().Get("Retry-After") != "2" {
+		t.Errorf("Expected 429 with Retry-After 2, got %d and %q", response.Code, response.Header().Get("Retry-After"))
+	}
+	if got := testutil.ToFloat64(testRegistry.HTTPRejectedRequests.WithLabelValues(rejectRateLimit)); got != rejected+1 {
+		t.Errorf("Expected the rejection to be counted, got %v", got-rejected)
+	}
+
+	// Probes are not limited
+	if response := get(t, handler, "/-/healthy"); response.Code != http.StatusOK {
+		t.Errorf("Expected the health probe to bypass the rate limit, got %d", response.Code)
+	}
+}
+
+func TestMaxConcurrentScrapes(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		w.Write([]byte("# TYPE slurm_jobs gauge\nslurm_jobs 1\n"))
+	}))
+	defer upstream.Close()
+
+	srv := newTestServer(t, upstream.URL, func(cfg *config.Config) {
+		cfg.Server.MaxConcurrentScrapes = 1
+	})
+	handler := srv.Handler()
+	rejected := testutil.ToFloat64(testRegistry.HTTPRejectedRequests.WithLabelValues(rejectMaxConcurrent))
+
+	first := make(chan int)
+	go func() {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics/jobs", nil))
+		first <- recorder.Code
+	}()
+	<-started
+
+	response := get(t, handler, "/api/v1/metrics")
+	if response.Code != http.StatusServiceUnavailable || response.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected 503 with Retry-After 1, got %d and %q", response.Code, response.Header().Get("Retry-After"))
+	}
+	if got := testutil.ToFloat64(testRegistry.HTTPRejectedRequests.WithLabelValues(rejectMaxConcurrent)); got != rejected+1 {
+		t.Errorf("Expected the rejection to be counted, got %v", got-rejected)
+	}
+
+	close(release)
+	if code := <-first; code != http.StatusOK {
+		t.Errorf("Expected the running scrape to succeed, got %d", code)
+	}
+
+	// The slot is free again
+	if response := get(t, handler, "/metrics/jobs"); response.Code != http.StatusOK {
+		t.Errorf("Expected a scrape after the first one to succeed, got %d", response.Code)
+	}
+}
@@ -0,0 +1,137 @@
+package topology
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+// nodeLabel is the label identifying the node of a series
+const nodeLabel = "node"
+
+// fileStamp identifies a version of a file on disk
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Enricher adds switch, rack and chassis labels to node series
+type Enricher struct {
+	topologyFile string
+	mappingFile  string
+	logger       *slog.Logger
+
+	mu     sync.RWMutex
+	nodes  Map
+	stamps map[string]fileStamp
+}
+
+// NewEnricher creates an enricher and loads the configured files
+func NewEnricher(cfg config.TopologyConfig, logger *slog.Logger) (*Enricher, error) {
+	e := &Enricher{
+		topologyFile: cfg.TopologyFile,
+		mappingFile:  cfg.MappingFile,
+		logger:       logger,
+	}
+
+	if err := e.load(e.currentStamps()); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// currentStamps returns the stamps of the configured files, omitting the
+// ones that cannot be read
+func (e *Enricher) currentStamps() map[string]fileStamp {
+	stamps := make(map[string]fileStamp, 2)
+	for _, path := range []string{e.topologyFile, e.mappingFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps
+}
+
+// load parses the files and records the stamps they were loaded at
+func (e *Enricher) load(stamps map[string]fileStamp) error {
+	nodes, err := Load(e.topologyFile, e.mappingFile)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.nodes = nodes
+	e.stamps = stamps
+	e.mu.Unlock()
+
+	e.logger.Info("loaded node topology",
+		"topology_file", e.topologyFile,
+		"mapping_file", e.mappingFile,
+		"nodes", len(nodes))
+
+	return nil
+}
+
+// reloadIfChanged reloads the files when one of them changed. Files that fail
+// to load keep the previous topology in place.
+func (e *Enricher) reloadIfChanged() {
+	stamps := e.currentStamps()
+
+	e.mu.RLock()
+	changed := len(stamps) != len(e.stamps)
+	for path, stamp := range stamps {
+		if previous, ok := e.stamps[path]; !ok || !previous.modTime.Equal(stamp.modTime) || previous.size != stamp.size {
+			changed = true
+		}
+	}
+	e.mu.RUnlock()
+
+	if !changed {
+		return
+	}
+
+	if err := e.load(stamps); err != nil {
+		e.logger.Warn("failed to reload node topology, keeping previous topology", "error", err)
+	}
+}
+
+// Enrich adds the topology labels to every sample carrying a known node label.
+// Labels already present on a sample are left untouched.
+func (e *Enricher) Enrich(families []*openmetrics.Family) {
+	e.reloadIfChanged()
+
+	e.mu.RLock()
+	nodes := e.nodes
+	e.mu.RUnlock()
+
+	for _, family := range families {
+		for i := range family.Samples {
+			sample := &family.Samples[i]
+			node, ok := sample.Label(nodeLabel)
+			if !ok {
+				continue
+			}
+			location, ok := nodes[node]
+			if !ok {
+				continue
+			}
+			for _, label := range Labels {
+				value, ok := location[label]
+				if !ok || value == "" {
+					continue
+				}
+				if _, exists := sample.Label(label); !exists {
+					sample.Labels = append(sample.Labels, openmetrics.Label{Name: label, Value: value})
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package topology maps Slurm node names to their switch, rack and chassis
+package topology
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/hostlist"
+	"gopkg.in/yaml.v3"
+)
+
+// Labels are the topology labels added to node series, in output order
+var Labels = []string{"switch", "rack", "chassis"}
+
+// Location holds the topology labels of a single node
+type Location map[string]string
+
+// Map holds the location of every known node
+type Map map[string]Location
+
+// set merges the given labels into the location of a node
+func (m Map) set(node string, labels Location) {
+	location, ok := m[node]
+	if !ok {
+		location = make(Location, len(labels))
+		m[node] = location
+	}
+	for label, value := range labels {
+		location[label] = value
+	}
+}
+
+// ParseTopologyConf reads a topology.conf for the tree plugin and returns the
+// leaf switch of every node listed in a "SwitchName=... Nodes=..." line
+func ParseTopologyConf(r io.Reader) (Map, error) {
+	topology := make(Map)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var switchName, nodes string
+		for _, field := range strings.Fields(line) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid field %q", lineNumber, field)
+			}
+			switch strings.ToLower(key) {
+			case "switchname":
+				switchName = value
+			case "nodes":
+				nodes = value
+			}
+		}
+
+		if switchName == "" {
+			return nil, fmt.Errorf("line %d: missing SwitchName", lineNumber)
+		}
+		if nodes == "" {
+			// Upper level switches only list other switches
+			continue
+		}
+
+		hosts, err := hostlist.Expand(nodes)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		for _, host := range hosts {
+			topology.set(host, Location{"switch": switchName})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return topology, nil
+}
+
+// ParseMapping reads a YAML mapping from hostlist expressions to labels:
+//
+//	nodes:
+//	  "c[001-064]":
+//	    rack: "r01"
+//	    chassis: "ch01"
+func ParseMapping(r io.Reader) (Map, error) {
+	var document struct {
+		Nodes map[string]Location `yaml:"nodes"`
+	}
+	if err := yaml.NewDecoder(r).Decode(&document); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	topology := make(Map)
+	for expr, labels := range document.Nodes {
+		for label := range labels {
+			if !isSupportedLabel(label) {
+				return nil, fmt.Errorf("nodes.%s: unsupported label %q, expected one of %s",
+					expr, label, strings.Join(Labels, ", "))
+			}
+		}
+
+		hosts, err := hostlist.Expand(expr)
+		if err != nil {
+			return nil, fmt.Errorf("nodes.%s: %w", expr, err)
+		}
+		for _, host := range hosts {
+			topology.set(host, labels)
+		}
+	}
+
+	return topology, nil
+}
+
+// Load builds the node map from a topology.conf and a mapping file. Either
+// path may be empty; labels from the mapping file take precedence.
+func Load(topologyFile, mappingFile string) (Map, error) {
+	topology := make(Map)
+
+	if topologyFile != "" {
+		parsed, err := parseFile(topologyFile, ParseTopologyConf)
+		if err != nil {
+			return nil, err
+		}
+		topology = parsed
+	}
+
+	if mappingFile != "" {
+		mapping, err := parseFile(mappingFile, ParseMapping)
+		if err != nil {
+			return nil, err
+		}
+		for node, location := range mapping {
+			topology.set(node, location)
+		}
+	}
+
+	return topology, nil
+}
+
+// parseFile opens path and hands it to the parser
+func parseFile(path string, parse func(io.Reader) (Map, error)) (Map, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	topology, err := parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return topology, nil
+}
+
+// isSupportedLabel reports whether label is one of the topology labels
+func isSupportedLabel(label string) bool {
+	for _, l := range Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package topology
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/config"
+	"github.com/sckyzo/slurm_prometheus_exporter/internal/openmetrics"
+)
+
+const topologyConf = `# Tree topology
+SwitchName=s0 Nodes=c[1-2] LinkSpeed=100
+SwitchName=s1 Nodes=c[3-4],gpu01
+SwitchName=top Switches=s[0-1]
+`
+
+func TestParseTopologyConf(t *testing.T) {
+	topology, err := ParseTopologyConf(strings.NewReader(topologyConf))
+	if err != nil {
+		t.Fatalf("Failed to parse topology.conf: %v", err)
+	}
+
+	expected := map[string]string{"c1": "s0", "c2": "s0", "c3": "s1", "c4": "s1", "gpu01": "s1"}
+	if len(topology) != len(expected) {
+		t.Errorf("Expected %d nodes, got %d", len(expected), len(topology))
+	}
+	for node, sw := range expected {
+		if got := topology[node]["switch"]; got != sw {
+			t.Errorf("Expected %s on switch %s, got %q", node, sw, got)
+		}
+	}
+
+	if _, err := ParseTopologyConf(strings.NewReader("Nodes=c[1-2]\n")); err == nil {
+		t.Error("Expected an error for a line without SwitchName")
+	}
+}
+
+func TestEnricher(t *testing.T) {
+	dir := t.TempDir()
+	topologyFile := filepath.Join(dir, "topology.conf")
+	mappingFile := filepath.Join(dir, "topology.yaml")
+	if err := os.WriteFile(topologyFile, []byte(topologyConf), 0o644); err != nil {
+		t.Fatalf("Failed to write topology.conf: %v", err)
+	}
+	mapping := `nodes:
+  "c[1-4]":
+    rack: "r01"
+  "c[3-4]":
+    chassis: "ch02"
+`
+	if err := os.WriteFile(mappingFile, []byte(mapping), 0o644); err != nil {
+		t.Fatalf("Failed to write mapping: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	e, err := NewEnricher(config.TopologyConfig{Enabled: true, TopologyFile: topologyFile, MappingFile: mappingFile}, logger)
+	if err != nil {
+		t.Fatalf("Failed to create enricher: %v", err)
+	}
+
+	families, err := openmetrics.Parse(strings.NewReader(`# TYPE slurm_node_cpus gauge
+slurm_node_cpus{node="c1"} 2
+slurm_node_cpus{node="c3"} 2
+slurm_node_cpus{node="login1"} 4
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	e.Enrich(families)
+
+	var out strings.Builder
+	if err := openmetrics.Write(&out, families); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	for _, want := range []string{
+		`slurm_node_cpus{node="c1",switch="s0",rack="r01"} 2`,
+		`slurm_node_cpus{node="c3",switch="s1",rack="r01",chassis="ch02"} 2`,
+		`slurm_node_cpus{node="login1"} 4`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}